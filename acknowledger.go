@@ -0,0 +1,226 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAckLinger time.Duration = 200 * time.Millisecond
+)
+
+type AcknowledgerOptions struct {
+	batchSize    int
+	linger       time.Duration
+	errorHandler func(err *AckError)
+}
+
+type AcknowledgerOption func(*AcknowledgerOptions)
+
+// WithAckBatchSize sets how many receipt handles trigger a flush, 1~16.
+func WithAckBatchSize(batchSize int) AcknowledgerOption {
+	return func(o *AcknowledgerOptions) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithAckLinger sets how long a receipt handle is buffered at most before it is flushed.
+func WithAckLinger(linger time.Duration) AcknowledgerOption {
+	return func(o *AcknowledgerOptions) {
+		o.linger = linger
+	}
+}
+
+// WithAckErrorHandler sets a callback for every receipt handle which failed to be deleted,
+// in addition to the result returned by Ack.
+func WithAckErrorHandler(handler func(err *AckError)) AcknowledgerOption {
+	return func(o *AcknowledgerOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// AckError reports a receipt handle which could not be deleted. ErrorCode and ErrorMessage
+// are set when the handle was reported in FailedMessages, otherwise Err holds the error of
+// the whole BatchDeleteMessage request.
+type AckError struct {
+	ReceiptHandle string
+	ErrorCode     string
+	ErrorMessage  string
+	Err           error
+}
+
+func (e *AckError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("ali_mns: delete message %s failed, %v", e.ReceiptHandle, e.Err)
+	}
+	return fmt.Sprintf("ali_mns: delete message %s failed, code: %s, message: %s", e.ReceiptHandle, e.ErrorCode, e.ErrorMessage)
+}
+
+func (e *AckError) Unwrap() error {
+	return e.Err
+}
+
+type pendingAck struct {
+	receiptHandle string
+	result        chan error
+}
+
+// Acknowledger buffers receipt handles and deletes them with BatchDeleteMessage, either
+// when a full batch is collected or when the oldest handle has lingered long enough.
+type Acknowledger struct {
+	queue   AliMNSQueue
+	options AcknowledgerOptions
+
+	lock     sync.Mutex
+	pending  []pendingAck
+	timer    *time.Timer
+	closed   bool
+	flushes  sync.WaitGroup
+	inflight map[chan struct{}]bool
+}
+
+func NewAcknowledger(queue AliMNSQueue, options ...AcknowledgerOption) (*Acknowledger, error) {
+	if queue == nil {
+		return nil, fmt.Errorf("ali_mns: acknowledger queue could not be nil")
+	}
+
+	o := AcknowledgerOptions{
+		batchSize: int(DefaultNumOfMessages),
+		linger:    DefaultAckLinger,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if o.batchSize <= 0 || o.batchSize > int(DefaultNumOfMessages) {
+		return nil, fmt.Errorf("ali_mns: acknowledger batch size is not in range of (1~%d)", DefaultNumOfMessages)
+	}
+	if o.linger <= 0 {
+		return nil, fmt.Errorf("ali_mns: acknowledger linger must be positive")
+	}
+
+	return &Acknowledger{queue: queue, options: o, inflight: map[chan struct{}]bool{}}, nil
+}
+
+// Ack buffers receiptHandle for deletion. The returned channel receives nil once the
+// message is deleted, or an *AckError.
+func (p *Acknowledger) Ack(receiptHandle string) <-chan error {
+	result := make(chan error, 1)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		result <- &AckError{ReceiptHandle: receiptHandle, Err: fmt.Errorf("ali_mns: acknowledger is closed")}
+		return result
+	}
+
+	p.pending = append(p.pending, pendingAck{receiptHandle: receiptHandle, result: result})
+	if len(p.pending) >= p.options.batchSize {
+		p.flushLocked()
+	} else if p.timer == nil {
+		p.timer = time.AfterFunc(p.options.linger, p.Flush)
+	}
+	return result
+}
+
+// Flush sends the buffered receipt handles without waiting for them to be deleted.
+func (p *Acknowledger) Flush() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.flushLocked()
+}
+
+// FlushWait sends the buffered receipt handles and waits until they and every batch sent
+// before are deleted or failed, or ctx is done. Unlike Close it keeps the acknowledger open.
+func (p *Acknowledger) FlushWait(ctx context.Context) error {
+	p.lock.Lock()
+	p.flushLocked()
+	batches := make([]chan struct{}, 0, len(p.inflight))
+	for done := range p.inflight {
+		batches = append(batches, done)
+	}
+	p.lock.Unlock()
+
+	for _, done := range batches {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close flushes the buffered receipt handles and waits until all of them are deleted.
+// Later calls to Ack fail.
+func (p *Acknowledger) Close() {
+	p.lock.Lock()
+	p.closed = true
+	p.flushLocked()
+	p.lock.Unlock()
+
+	p.flushes.Wait()
+}
+
+func (p *Acknowledger) flushLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if len(p.pending) == 0 {
+		return
+	}
+
+	batch := p.pending
+	p.pending = nil
+
+	done := make(chan struct{})
+	p.inflight[done] = true
+	p.flushes.Add(1)
+	go func() {
+		defer p.flushes.Done()
+		p.deleteBatch(batch)
+
+		p.lock.Lock()
+		delete(p.inflight, done)
+		p.lock.Unlock()
+		close(done)
+	}()
+}
+
+func (p *Acknowledger) deleteBatch(batch []pendingAck) {
+	handles := make([]string, 0, len(batch))
+	for _, ack := range batch {
+		handles = append(handles, ack.receiptHandle)
+	}
+
+	resp, err := p.queue.BatchDeleteMessage(handles...)
+
+	failed := map[string]MessageDeleteFailEntry{}
+	for _, entry := range resp.FailedMessages {
+		failed[entry.ReceiptHandle] = entry
+	}
+
+	for _, ack := range batch {
+		var ackErr *AckError
+		if entry, ok := failed[ack.receiptHandle]; ok {
+			ackErr = &AckError{ReceiptHandle: ack.receiptHandle, ErrorCode: entry.ErrorCode, ErrorMessage: entry.ErrorMessage}
+		} else if err != nil && len(failed) == 0 {
+			ackErr = &AckError{ReceiptHandle: ack.receiptHandle, Err: err}
+		}
+
+		if ackErr == nil {
+			ack.result <- nil
+			continue
+		}
+		if p.options.errorHandler != nil {
+			p.options.errorHandler(ackErr)
+		}
+		ack.result <- ackErr
+	}
+}
@@ -0,0 +1,243 @@
+package ali_mns
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	DefaultAdaptiveDecreaseFactor float64       = 0.5
+	DefaultAdaptiveIncreaseStep   float64       = 10
+	DefaultAdaptiveInterval       time.Duration = time.Second
+)
+
+// AdaptiveRateLimiter adjusts a RateLimiter the AIMD way: the rate is multiplied by the
+// decrease factor when the server answers QpsLimitExceeded, and grows by the increase step
+// per interval of successful requests until it reaches the maximum again.
+type AdaptiveRateLimiter struct {
+	limiter  *RateLimiter
+	minQPS   float64
+	maxQPS   float64
+	factor   float64
+	step     float64
+	interval time.Duration
+
+	// decreases and increases are paced separately, so that frequent successes never hide
+	// throttling; any throttled response also postpones the next increase
+	lock         sync.Mutex
+	lastDecrease time.Time
+	lastIncrease time.Time
+	lastThrottle time.Time
+}
+
+type AdaptiveRateLimiterOptions struct {
+	initialQPS float64
+	burst      int
+	factor     float64
+	step       float64
+	interval   time.Duration
+}
+
+type AdaptiveRateLimiterOption func(*AdaptiveRateLimiterOptions)
+
+// WithAdaptiveInitialRate sets the rate to start with instead of the maximum.
+func WithAdaptiveInitialRate(qps float64) AdaptiveRateLimiterOption {
+	return func(o *AdaptiveRateLimiterOptions) {
+		o.initialQPS = qps
+	}
+}
+
+// WithAdaptiveBurst sets the burst of the underlying RateLimiter, the minimum rate is used
+// by default.
+func WithAdaptiveBurst(burst int) AdaptiveRateLimiterOption {
+	return func(o *AdaptiveRateLimiterOptions) {
+		o.burst = burst
+	}
+}
+
+// WithAdaptiveDecrease sets the factor in (0, 1) the rate is multiplied by on throttling.
+func WithAdaptiveDecrease(factor float64) AdaptiveRateLimiterOption {
+	return func(o *AdaptiveRateLimiterOptions) {
+		o.factor = factor
+	}
+}
+
+// WithAdaptiveIncrease sets how much the rate grows per interval of successful requests.
+func WithAdaptiveIncrease(step float64) AdaptiveRateLimiterOption {
+	return func(o *AdaptiveRateLimiterOptions) {
+		o.step = step
+	}
+}
+
+// WithAdaptiveInterval sets how often the rate may be lowered or raised, a burst of
+// throttled responses within one interval lowers the rate only once.
+func WithAdaptiveInterval(interval time.Duration) AdaptiveRateLimiterOption {
+	return func(o *AdaptiveRateLimiterOptions) {
+		o.interval = interval
+	}
+}
+
+func NewAdaptiveRateLimiter(minQPS float64, maxQPS float64, options ...AdaptiveRateLimiterOption) (*AdaptiveRateLimiter, error) {
+	o := AdaptiveRateLimiterOptions{
+		initialQPS: maxQPS,
+		burst:      int(minQPS),
+		factor:     DefaultAdaptiveDecreaseFactor,
+		step:       DefaultAdaptiveIncreaseStep,
+		interval:   DefaultAdaptiveInterval,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if minQPS <= 0 || maxQPS < minQPS {
+		return nil, fmt.Errorf("ali_mns: adaptive rate limiter needs 0 < min qps <= max qps")
+	}
+	if o.factor <= 0 || o.factor >= 1 {
+		return nil, fmt.Errorf("ali_mns: adaptive decrease factor is not in range of (0~1)")
+	}
+	if o.step <= 0 || o.interval <= 0 {
+		return nil, fmt.Errorf("ali_mns: adaptive increase step and interval must be positive")
+	}
+	if o.initialQPS < minQPS || o.initialQPS > maxQPS {
+		return nil, fmt.Errorf("ali_mns: adaptive initial qps is not in range of (%v~%v)", minQPS, maxQPS)
+	}
+
+	now := time.Now()
+	return &AdaptiveRateLimiter{
+		limiter:      NewRateLimiter(o.initialQPS, o.burst),
+		minQPS:       minQPS,
+		maxQPS:       maxQPS,
+		factor:       o.factor,
+		step:         o.step,
+		interval:     o.interval,
+		lastDecrease: now,
+		lastIncrease: now,
+	}, nil
+}
+
+// RateLimiter returns the limiter whose rate is adjusted.
+func (p *AdaptiveRateLimiter) RateLimiter() *RateLimiter {
+	return p.limiter
+}
+
+// Rate returns the currently allowed requests per second.
+func (p *AdaptiveRateLimiter) Rate() float64 {
+	return p.limiter.Rate()
+}
+
+func (p *AdaptiveRateLimiter) Wait(ctx context.Context) error {
+	return p.limiter.Wait(ctx)
+}
+
+// Throttled lowers the rate unless it was lowered within the last interval.
+func (p *AdaptiveRateLimiter) Throttled() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	p.lastThrottle = now
+	if now.Sub(p.lastDecrease) < p.interval {
+		return
+	}
+	rate := p.limiter.Rate() * p.factor
+	if rate < p.minQPS {
+		rate = p.minQPS
+	}
+	p.limiter.SetRate(rate)
+	p.lastDecrease = now
+}
+
+// Succeeded raises the rate by one step once per interval, provided no request was
+// throttled within the last interval.
+func (p *AdaptiveRateLimiter) Succeeded() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastIncrease) < p.interval || now.Sub(p.lastThrottle) < p.interval {
+		return
+	}
+	rate := p.limiter.Rate()
+	if rate >= p.maxQPS {
+		return
+	}
+	rate += p.step
+	if rate > p.maxQPS {
+		rate = p.maxQPS
+	}
+	p.limiter.SetRate(rate)
+	p.lastIncrease = now
+}
+
+// observe feeds the outcome of a request to the limiter.
+func (p *AdaptiveRateLimiter) observe(resp *fasthttp.Response) {
+	if resp == nil {
+		return
+	}
+	switch statusCode := resp.Header.StatusCode(); statusCode {
+	case fasthttp.StatusOK, fasthttp.StatusCreated, fasthttp.StatusNoContent:
+		p.Succeeded()
+	default:
+		if isThrottledResponse(resp.Body()) {
+			p.Throttled()
+		}
+	}
+}
+
+// batchEntryErrors matches the entries of a partially failed batch send or delete, which
+// are <Message> or <Error> elements with their own ErrorCode.
+type batchEntryErrors struct {
+	Entries []struct {
+		ErrorCode string `xml:"ErrorCode"`
+	} `xml:",any"`
+}
+
+// isThrottledResponse reports whether the request or any entry of a batch was rate limited.
+func isThrottledResponse(body []byte) bool {
+	errResp := ErrorResponse{}
+	if xml.Unmarshal(body, &errResp) == nil && errResp.Code != "" {
+		return errResp.Code == "QpsLimitExceeded"
+	}
+
+	batchErr := batchEntryErrors{}
+	if xml.Unmarshal(body, &batchErr) != nil {
+		return false
+	}
+	for _, entry := range batchErr.Entries {
+		if entry.ErrorCode == "QpsLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+type adaptiveClient struct {
+	MNSClient
+	limiter *AdaptiveRateLimiter
+	wait    bool
+}
+
+// NewAdaptiveRateLimitedClient returns a client which passes every request through
+// limiter and adjusts it by the responses, so that all queues, topics and managers created
+// from it adapt to one account wide limit.
+func NewAdaptiveRateLimitedClient(client MNSClient, limiter *AdaptiveRateLimiter) MNSClient {
+	return &adaptiveClient{MNSClient: client, limiter: limiter, wait: true}
+}
+
+func (p *adaptiveClient) Send(method Method, headers map[string]string, message interface{}, resource string) (*fasthttp.Response, error) {
+	if p.wait {
+		p.limiter.Wait(context.Background())
+	}
+	resp, err := p.MNSClient.Send(method, headers, message, resource)
+	if err == nil {
+		p.limiter.observe(resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,283 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	DefaultAsyncWorkers   int = 8
+	DefaultAsyncQueueSize int = 1024
+)
+
+// BackpressurePolicy decides what an AsyncSender does with a message when its queue is full.
+type BackpressurePolicy string
+
+const (
+	// BACKPRESSURE_BLOCK waits until there is room in the queue.
+	BACKPRESSURE_BLOCK BackpressurePolicy = "BLOCK"
+	// BACKPRESSURE_DROP discards the message, its future fails with ERR_MNS_ASYNC_QUEUE_FULL.
+	BACKPRESSURE_DROP BackpressurePolicy = "DROP"
+	// BACKPRESSURE_ERROR rejects the message by returning ERR_MNS_ASYNC_QUEUE_FULL.
+	BACKPRESSURE_ERROR BackpressurePolicy = "ERROR"
+)
+
+// SendCallback receives the result of an asynchronous send or publish.
+type SendCallback func(resp MessageSendResponse, err error)
+
+type AsyncSenderOptions struct {
+	workers      int
+	queueSize    int
+	policy       BackpressurePolicy
+	errorHandler func(err error)
+}
+
+type AsyncSenderOption func(*AsyncSenderOptions)
+
+// WithAsyncWorkers sets how many requests are sent at the same time.
+func WithAsyncWorkers(workers int) AsyncSenderOption {
+	return func(o *AsyncSenderOptions) {
+		o.workers = workers
+	}
+}
+
+// WithAsyncQueueSize sets how many messages may wait for a worker.
+func WithAsyncQueueSize(size int) AsyncSenderOption {
+	return func(o *AsyncSenderOptions) {
+		o.queueSize = size
+	}
+}
+
+// WithAsyncBackpressure sets the policy applied when the queue is full, BACKPRESSURE_BLOCK
+// by default.
+func WithAsyncBackpressure(policy BackpressurePolicy) AsyncSenderOption {
+	return func(o *AsyncSenderOptions) {
+		o.policy = policy
+	}
+}
+
+// WithAsyncErrorHandler recovers panics of callbacks and passes them to handler. Without
+// it a panicking callback crashes the program like any other goroutine.
+func WithAsyncErrorHandler(handler func(err error)) AsyncSenderOption {
+	return func(o *AsyncSenderOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// AsyncSender sends and publishes messages on a bounded pool of goroutines. One sender is
+// meant to be shared by all queues and topics of a client, see WithAsyncSender.
+type AsyncSender struct {
+	options AsyncSenderOptions
+	tasks   chan func()
+	workers sync.WaitGroup
+	dropped int64
+
+	closeLock sync.RWMutex
+	closed    bool
+
+	// messages are numbered in submission order, so that Flush only waits for the ones
+	// submitted before it was called
+	pendingLock sync.Mutex
+	nextSeq     uint64
+	doneSeq     uint64
+	pending     map[uint64]bool
+	advanced    chan struct{}
+}
+
+func NewAsyncSender(options ...AsyncSenderOption) (*AsyncSender, error) {
+	o := AsyncSenderOptions{
+		workers:   DefaultAsyncWorkers,
+		queueSize: DefaultAsyncQueueSize,
+		policy:    BACKPRESSURE_BLOCK,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if o.workers <= 0 {
+		return nil, fmt.Errorf("ali_mns: async workers must be positive")
+	}
+	if o.queueSize < 0 {
+		return nil, fmt.Errorf("ali_mns: async queue size could not be negative")
+	}
+	switch o.policy {
+	case BACKPRESSURE_BLOCK, BACKPRESSURE_DROP, BACKPRESSURE_ERROR:
+	default:
+		return nil, fmt.Errorf("ali_mns: unknown backpressure policy %q", o.policy)
+	}
+
+	sender := &AsyncSender{
+		options:  o,
+		tasks:    make(chan func(), o.queueSize),
+		pending:  map[uint64]bool{},
+		advanced: make(chan struct{}),
+	}
+	for i := 0; i < o.workers; i++ {
+		sender.workers.Add(1)
+		go sender.work()
+	}
+	return sender, nil
+}
+
+// SendMessageAsync sends message to queue in the background. The optional callback is
+// called with the result before the future is resolved. err is only set when the message
+// was rejected, a dropped message resolves its future with ERR_MNS_ASYNC_QUEUE_FULL.
+func (p *AsyncSender) SendMessageAsync(queue AliMNSQueue, message MessageSendRequest, callback ...SendCallback) (*SendFuture, error) {
+	return p.submit(func() (MessageSendResponse, error) { return queue.SendMessage(message) }, callback)
+}
+
+// PublishMessageAsync publishes message to topic in the background, see SendMessageAsync.
+func (p *AsyncSender) PublishMessageAsync(topic AliMNSTopic, message MessagePublishRequest, callback ...SendCallback) (*SendFuture, error) {
+	return p.submit(func() (MessageSendResponse, error) { return topic.PublishMessage(message) }, callback)
+}
+
+// SendMessageAsync sends message on the AsyncSender of the queue, see WithAsyncSender.
+func (p *MNSQueue) SendMessageAsync(message MessageSendRequest, callback ...SendCallback) (*SendFuture, error) {
+	if p.asyncSender == nil {
+		return nil, fmt.Errorf("ali_mns: queue %s has no async sender", p.name)
+	}
+	return p.asyncSender.SendMessageAsync(p, message, callback...)
+}
+
+// PublishMessageAsync publishes message on the AsyncSender of the topic, see WithAsyncSender.
+func (p *MNSTopic) PublishMessageAsync(message MessagePublishRequest, callback ...SendCallback) (*SendFuture, error) {
+	if p.asyncSender == nil {
+		return nil, fmt.Errorf("ali_mns: topic %s has no async sender", p.name)
+	}
+	return p.asyncSender.PublishMessageAsync(p, message, callback...)
+}
+
+// Dropped returns how many messages were dropped by BACKPRESSURE_DROP.
+func (p *AsyncSender) Dropped() int64 {
+	return atomic.LoadInt64(&p.dropped)
+}
+
+// Flush waits until every message submitted before the call has been sent or failed, or
+// ctx is done. Messages submitted during the call are not waited for.
+func (p *AsyncSender) Flush(ctx context.Context) error {
+	p.pendingLock.Lock()
+	target := p.nextSeq
+	for p.doneSeq < target {
+		advanced := p.advanced
+		p.pendingLock.Unlock()
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		p.pendingLock.Lock()
+	}
+	p.pendingLock.Unlock()
+	return nil
+}
+
+// Close stops accepting messages and waits until the queued ones are sent.
+func (p *AsyncSender) Close() {
+	p.closeLock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.closeLock.Unlock()
+
+	p.workers.Wait()
+}
+
+func (p *AsyncSender) submit(send func() (MessageSendResponse, error), callbacks []SendCallback) (*SendFuture, error) {
+	future := newSendFuture()
+	complete := func(resp MessageSendResponse, err error) {
+		defer future.resolve(resp, err)
+		for _, callback := range callbacks {
+			if callback != nil {
+				p.callCallback(callback, resp, err)
+			}
+		}
+	}
+	var seq uint64
+	task := func() {
+		defer p.done(seq)
+		complete(send())
+	}
+
+	p.closeLock.RLock()
+	defer p.closeLock.RUnlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("ali_mns: async sender is closed")
+	}
+
+	seq = p.begin()
+	if p.options.policy == BACKPRESSURE_BLOCK {
+		p.tasks <- task
+		return future, nil
+	}
+
+	select {
+	case p.tasks <- task:
+		return future, nil
+	default:
+	}
+
+	p.done(seq)
+	err := ERR_MNS_ASYNC_QUEUE_FULL.New(errors.Params{"size": p.options.queueSize})
+	if p.options.policy == BACKPRESSURE_ERROR {
+		return nil, err
+	}
+	atomic.AddInt64(&p.dropped, 1)
+	complete(MessageSendResponse{}, err)
+	return future, nil
+}
+
+func (p *AsyncSender) callCallback(callback SendCallback, resp MessageSendResponse, err error) {
+	if p.options.errorHandler != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				p.options.errorHandler(fmt.Errorf("ali_mns: send callback panic: %v", r))
+			}
+		}()
+	}
+	callback(resp, err)
+}
+
+func (p *AsyncSender) work() {
+	defer p.workers.Done()
+	for task := range p.tasks {
+		task()
+	}
+}
+
+// begin numbers a submitted message.
+func (p *AsyncSender) begin() uint64 {
+	p.pendingLock.Lock()
+	defer p.pendingLock.Unlock()
+
+	seq := p.nextSeq
+	p.nextSeq++
+	p.pending[seq] = true
+	return seq
+}
+
+// done completes the message numbered seq and wakes up Flush once every message before the
+// first pending one has completed.
+func (p *AsyncSender) done(seq uint64) {
+	p.pendingLock.Lock()
+	defer p.pendingLock.Unlock()
+
+	delete(p.pending, seq)
+	advanced := false
+	for p.doneSeq < p.nextSeq && !p.pending[p.doneSeq] {
+		p.doneSeq++
+		advanced = true
+	}
+	if advanced {
+		close(p.advanced)
+		p.advanced = make(chan struct{})
+	}
+}
@@ -0,0 +1,68 @@
+package ali_mns
+
+import (
+	"sync"
+)
+
+const (
+	// MaxBatchBodyBytes is the total body size the service accepts in one batch request.
+	MaxBatchBodyBytes int = 65536
+
+	// BatchChunkFailedErrorCode marks the entries of an oversized batch call whose chunk
+	// request failed as a whole, ErrorMessage holds the error of that request.
+	BatchChunkFailedErrorCode = "BatchChunkFailed"
+)
+
+type batchChunk struct {
+	start int
+	end   int
+}
+
+// splitBatchMessages splits messages into chunks of at most DefaultNumOfMessages entries
+// and MaxBatchBodyBytes of body. A message larger than the limit gets a chunk of its own.
+func splitBatchMessages(messages []MessageSendRequest) []batchChunk {
+	chunks := []batchChunk{}
+	start, size := 0, 0
+	for i, message := range messages {
+		bodySize := len(message.MessageBody)
+		if i > start && (i-start >= int(DefaultNumOfMessages) || size+bodySize > MaxBatchBodyBytes) {
+			chunks = append(chunks, batchChunk{start: start, end: i})
+			start, size = i, 0
+		}
+		size += bodySize
+	}
+	return append(chunks, batchChunk{start: start, end: len(messages)})
+}
+
+// splitBatchHandles splits receipt handles into chunks of at most DefaultNumOfMessages.
+func splitBatchHandles(receiptHandles []string) []batchChunk {
+	chunks := []batchChunk{}
+	for start := 0; start < len(receiptHandles); start += int(DefaultNumOfMessages) {
+		end := start + int(DefaultNumOfMessages)
+		if end > len(receiptHandles) {
+			end = len(receiptHandles)
+		}
+		chunks = append(chunks, batchChunk{start: start, end: end})
+	}
+	return chunks
+}
+
+// runBatchChunks calls fn for every chunk index with at most parallelism calls at once.
+func runBatchChunks(n int, parallelism int, fn func(i int)) {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,120 @@
+package ali_mns
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gogap/errors"
+)
+
+// retryableBatchEntryCodes are the entry error codes worth sending again.
+var retryableBatchEntryCodes = map[string]bool{
+	"InternalError":      true,
+	"QpsLimitExceeded":   true,
+	"ServiceUnavailable": true,
+}
+
+// BatchEntryError is the failure of one entry of a batch request, Index is the position of
+// the entry in the request. ReceiptHandle is only set for BatchDeleteMessage.
+type BatchEntryError struct {
+	Index         int
+	ReceiptHandle string
+	ErrorCode     string
+	ErrorMessage  string
+}
+
+func (e *BatchEntryError) Error() string {
+	return fmt.Sprintf("ali_mns: batch entry %d failed, code: %s, message: %s", e.Index, e.ErrorCode, e.ErrorMessage)
+}
+
+// Retryable reports whether the entry failed for a transient reason.
+func (e *BatchEntryError) Retryable() bool {
+	return retryableBatchEntryCodes[e.ErrorCode]
+}
+
+// BatchError is returned by BatchSendMessage and BatchDeleteMessage when some or all
+// entries failed. It is still an ERR_MNS_BATCH_OP_FAIL error, so existing IsEqual checks
+// keep working.
+type BatchError struct {
+	errors.ErrCode
+	Total  int
+	Failed []*BatchEntryError
+}
+
+func (e *BatchError) Error() string {
+	codes := []string{}
+	for _, entry := range e.Failed {
+		codes = append(codes, fmt.Sprintf("%d:%s", entry.Index, entry.ErrorCode))
+	}
+	return fmt.Sprintf("%s, %d of %d entries failed [%s]", e.ErrCode.Error(), len(e.Failed), e.Total, strings.Join(codes, " "))
+}
+
+// AllFailed reports whether no entry of the batch succeeded.
+func (e *BatchError) AllFailed() bool {
+	return len(e.Failed) == e.Total
+}
+
+// FailedIndexes returns the input indexes of the failed entries in ascending order.
+func (e *BatchError) FailedIndexes() []int {
+	indexes := make([]int, 0, len(e.Failed))
+	for _, entry := range e.Failed {
+		indexes = append(indexes, entry.Index)
+	}
+	return indexes
+}
+
+// Unwrap exposes the entry errors to errors.As.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, entry := range e.Failed {
+		errs = append(errs, entry)
+	}
+	return errs
+}
+
+func newBatchError(total int, failed []*BatchEntryError) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BatchError{ErrCode: ERR_MNS_BATCH_OP_FAIL.New(), Total: total, Failed: failed}
+}
+
+// batchSendFailures collects the failed entries of a batch send response.
+func batchSendFailures(resp BatchMessageSendResponse) (failed []*BatchEntryError) {
+	for i, entry := range resp.Messages {
+		if entry.ErrorCode != "" {
+			failed = append(failed, &BatchEntryError{Index: i, ErrorCode: entry.ErrorCode, ErrorMessage: entry.ErrorMessage})
+		}
+	}
+	return
+}
+
+// batchDeleteFailures maps the failed entries of a batch delete response to the indexes of
+// receiptHandles.
+func batchDeleteFailures(receiptHandles []string, resp BatchMessageDeleteErrorResponse) (failed []*BatchEntryError) {
+	entries := map[string]MessageDeleteFailEntry{}
+	for _, entry := range resp.FailedMessages {
+		entries[entry.ReceiptHandle] = entry
+	}
+	for i, handle := range receiptHandles {
+		if entry, ok := entries[handle]; ok {
+			failed = append(failed, &BatchEntryError{Index: i, ReceiptHandle: handle, ErrorCode: entry.ErrorCode, ErrorMessage: entry.ErrorMessage})
+		}
+	}
+	return
+}
+
+func retryableIndexes(failed []*BatchEntryError) (indexes []int) {
+	for _, entry := range failed {
+		if entry.Retryable() {
+			indexes = append(indexes, entry.Index)
+		}
+	}
+	return
+}
+
+// batchRetryDelay backs off linearly between retry rounds.
+func batchRetryDelay(backoff time.Duration, attempt int) time.Duration {
+	return backoff * time.Duration(attempt+1)
+}
@@ -0,0 +1,85 @@
+package ali_mns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalBlobStore is a BlobStore keeping one file per blob in a local directory. It is
+// meant for tests and single host deployments.
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("ali_mns: blob store directory could not be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("ali_mns: create blob store directory failed: %w", err)
+	}
+	return &LocalBlobStore{dir: dir}, nil
+}
+
+func (p *LocalBlobStore) Put(key string, data []byte) error {
+	path, err := p.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(p.dir, ".tmp-"+key)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (p *LocalBlobStore) Get(key string) ([]byte, error) {
+	path, err := p.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (p *LocalBlobStore) Delete(key string) error {
+	path, err := p.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (p *LocalBlobStore) path(key string) (string, error) {
+	if !isValidBlobKey(key) {
+		return "", fmt.Errorf("ali_mns: invalid blob key %q", key)
+	}
+	return filepath.Join(p.dir, key), nil
+}
+
+// isValidBlobKey only accepts keys made of letters, digits, '-' and '_' so that keys read
+// from received messages can not escape the store directory.
+func isValidBlobKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package ali_mns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	neturl "net/url"
+	"strconv"
+)
+
+const (
+	ClaimCheckEnvelopeKind = "claim-check"
+
+	DefaultClaimCheckThreshold int = 65536
+)
+
+// BlobStore stores message bodies which are too large to be sent through MNS.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// ClaimCheckCodec offloads bodies larger than threshold bytes to a BlobStore and sends a
+// reference to the blob instead. Received references are resolved transparently. The
+// threshold also applies to the sent body: when the codecs after it, or the base64 body
+// encoding, grow a kept body beyond threshold, the body is offloaded anyway.
+//
+// Add it after a compression codec, so that bodies are measured compressed, and before an
+// encryption codec, so that the reference is encrypted too and the blob stays plaintext
+// only in the BlobStore; the base64 body encoding is always applied last.
+//
+// When deleteOnAck is set the blob is deleted after the message has been deleted through
+// DeleteMessage or BatchDeleteMessage of the receiving queue. Do not set it for bodies
+// published to topics with more than one subscription.
+type ClaimCheckCodec struct {
+	store       BlobStore
+	threshold   int
+	deleteOnAck bool
+}
+
+func NewClaimCheckCodec(store BlobStore, threshold int, deleteOnAck bool) *ClaimCheckCodec {
+	if threshold <= 0 {
+		threshold = DefaultClaimCheckThreshold
+	}
+	return &ClaimCheckCodec{store: store, threshold: threshold, deleteOnAck: deleteOnAck}
+}
+
+func (p *ClaimCheckCodec) EncodeBody(body string) (string, error) {
+	if len(body) <= p.threshold {
+		return body, nil
+	}
+	return p.offload(body)
+}
+
+func (p *ClaimCheckCodec) offload(body string) (string, error) {
+	key, err := newBlobKey()
+	if err != nil {
+		return "", newEncodeBodyError(ClaimCheckEnvelopeKind, err)
+	}
+	if err = p.store.Put(key, []byte(body)); err != nil {
+		return "", newEncodeBodyError(ClaimCheckEnvelopeKind, err)
+	}
+
+	return messageEnvelope{
+		kind:    ClaimCheckEnvelopeKind,
+		headers: neturl.Values{"key": {key}, "size": {strconv.Itoa(len(body))}},
+	}.String(), nil
+}
+
+func (p *ClaimCheckCodec) DecodeBody(body string) (string, error) {
+	key, ok := claimCheckKey(body)
+	if !ok {
+		return body, nil
+	}
+
+	data, err := p.store.Get(key)
+	if err != nil {
+		return "", newDecodeBodyError(ClaimCheckEnvelopeKind, err)
+	}
+	return string(data), nil
+}
+
+func (p *ClaimCheckCodec) releaser(body string) func() error {
+	if !p.deleteOnAck {
+		return nil
+	}
+
+	key, ok := claimCheckKey(body)
+	if !ok {
+		return nil
+	}
+	return func() error {
+		return p.store.Delete(key)
+	}
+}
+
+// IsClaimCheckMessageBody reports whether body is a reference written by a ClaimCheckCodec.
+func IsClaimCheckMessageBody(body string) bool {
+	_, ok := claimCheckKey(body)
+	return ok
+}
+
+func claimCheckKey(body string) (key string, ok bool) {
+	env, ok := parseMessageEnvelope(body)
+	if !ok || env.kind != ClaimCheckEnvelopeKind {
+		return "", false
+	}
+	key = env.headers.Get("key")
+	return key, key != ""
+}
+
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -90,6 +90,11 @@ type AliMNSClientConfig struct {
 	Credential      credentials.Credential
 	TimeoutSecond   int64
 	MaxConnsPerHost int
+	// ControlPlaneLimiter throttles management requests of queues, topics and
+	// subscriptions, DataPlaneLimiter throttles message requests. Either may be nil and
+	// both may be shared with other clients.
+	ControlPlaneLimiter *RateLimiter
+	DataPlaneLimiter    *RateLimiter
 }
 
 // NewClient Follow the Alibaba Cloud standards and set the AK (Access Key) and SK (Secret Key) in the environment variables.
@@ -187,6 +192,9 @@ func NewAliMNSClientWithConfig(clientConfig AliMNSClientConfig) (MNSClient, erro
 	//change to dial dual stack to support both ipv4 and ipv6
 	cli.client.DialDualStack = true
 
+	if clientConfig.ControlPlaneLimiter != nil || clientConfig.DataPlaneLimiter != nil {
+		return NewPlaneRateLimitedClient(cli, clientConfig.ControlPlaneLimiter, clientConfig.DataPlaneLimiter), nil
+	}
 	return cli, nil
 }
 
@@ -0,0 +1,474 @@
+package ali_mns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultConsumerConcurrency int           = 4
+	DefaultConsumerWaitSeconds int64         = 10
+	DefaultConsumerErrorDelay  time.Duration = time.Second
+)
+
+// Handler processes one received message. The message is deleted from the queue when the
+// handler returns nil, otherwise it becomes visible again after its visibility timeout.
+type Handler func(ctx context.Context, msg MessageReceiveResponse) error
+
+// Middleware wraps a Handler, e.g. to skip or instrument messages.
+type Middleware func(next Handler) Handler
+
+type ConsumerOptions struct {
+	concurrency  int
+	batchSize    int32
+	waitSeconds  int64
+	batchDelete  bool
+	errorDelay   time.Duration
+	errorHandler func(err error)
+	lease        []LeaseOption
+	deadLetter   *DeadLetterPolicy
+	acknowledger *Acknowledger
+	middlewares  []Middleware
+	redelivery   *RedeliveryBackoff
+	partitionKey func(msg MessageReceiveResponse) string
+}
+
+type ConsumerOption func(*ConsumerOptions)
+
+// WithConsumerConcurrency sets the number of messages handled in parallel.
+func WithConsumerConcurrency(concurrency int) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithConsumerBatchSize sets the maximum number of messages received per request, 1~16.
+func WithConsumerBatchSize(batchSize int32) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithConsumerWaitSeconds sets the long polling wait of each receive request, 0~30.
+func WithConsumerWaitSeconds(waitSeconds int64) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.waitSeconds = waitSeconds
+	}
+}
+
+// WithConsumerBatchDelete makes the consumer delete the handled messages of a received
+// batch with a single BatchDeleteMessage once all of them have been handled.
+func WithConsumerBatchDelete(enabled bool) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.batchDelete = enabled
+	}
+}
+
+// WithConsumerErrorDelay sets how long the consumer pauses after a failed receive request.
+func WithConsumerErrorDelay(delay time.Duration) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.errorDelay = delay
+	}
+}
+
+// WithConsumerErrorHandler sets the callback for receive, handler and delete errors. It
+// may be called from several goroutines at once.
+func WithConsumerErrorHandler(handler func(err error)) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// WithConsumerLeaseExtension keeps messages invisible while their handler is running, see
+// LeaseExtender. Lease errors are passed to the consumer error handler unless options
+// set another one.
+func WithConsumerLeaseExtension(options ...LeaseOption) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.lease = append([]LeaseOption{}, options...)
+	}
+}
+
+// WithConsumerAcknowledger deletes handled messages through acknowledger instead of one
+// DeleteMessage per message. Delete failures are reported to the error handler of the
+// acknowledger. Run flushes the acknowledger and waits for the deletes before it returns,
+// but does not close it, so it can be shared by several consumers of the same queue.
+func WithConsumerAcknowledger(acknowledger *Acknowledger) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.acknowledger = acknowledger
+	}
+}
+
+// WithConsumerDeadLetterPolicy forwards messages to the dead-letter queue of policy when
+// their handler failed on the last allowed delivery, or when they arrive after it.
+func WithConsumerDeadLetterPolicy(policy *DeadLetterPolicy) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.deadLetter = policy
+	}
+}
+
+// WithConsumerPartitionKey handles messages with the same non-empty partition key one after
+// another in the order they were received, while different keys are handled in parallel. A
+// message is only handled and deleted after all earlier messages of its key completed, and
+// it is skipped to reappear later when one of them failed.
+func WithConsumerPartitionKey(keyFunc func(msg MessageReceiveResponse) string) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.partitionKey = keyFunc
+	}
+}
+
+// WithConsumerRedeliveryBackoff redelivers messages whose handler failed after the delay
+// of backoff instead of the visibility timeout of the queue. Messages which are
+// dead-lettered are not affected.
+func WithConsumerRedeliveryBackoff(backoff *RedeliveryBackoff) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.redelivery = backoff
+	}
+}
+
+// WithConsumerMiddleware wraps the handler with middlewares, the first one is the outermost.
+func WithConsumerMiddleware(middlewares ...Middleware) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// ConsumerError is passed to the error handler when handling or deleting a message failed.
+type ConsumerError struct {
+	Message MessageReceiveResponse
+	Err     error
+}
+
+func (e *ConsumerError) Error() string {
+	return fmt.Sprintf("ali_mns: consume message %s failed, %v", e.Message.MessageId, e.Err)
+}
+
+func (e *ConsumerError) Unwrap() error {
+	return e.Err
+}
+
+// Consumer receives messages from a queue and dispatches them to a Handler on a bounded
+// pool of goroutines, deleting every message its handler succeeded on.
+type Consumer struct {
+	queue   AliMNSQueue
+	handler Handler
+	options ConsumerOptions
+	leases  *LeaseExtender
+
+	partitionLock sync.Mutex
+	partitions    map[string]*partitionTurn
+
+	running int32
+}
+
+func NewConsumer(queue AliMNSQueue, handler Handler, options ...ConsumerOption) (*Consumer, error) {
+	if queue == nil {
+		return nil, fmt.Errorf("ali_mns: consumer queue could not be nil")
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("ali_mns: consumer handler could not be nil")
+	}
+
+	o := ConsumerOptions{
+		concurrency: DefaultConsumerConcurrency,
+		batchSize:   DefaultNumOfMessages,
+		waitSeconds: DefaultConsumerWaitSeconds,
+		errorDelay:  DefaultConsumerErrorDelay,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if o.concurrency <= 0 {
+		return nil, fmt.Errorf("ali_mns: consumer concurrency must be positive")
+	}
+	if o.batchSize <= 0 || o.batchSize > DefaultNumOfMessages {
+		return nil, fmt.Errorf("ali_mns: consumer batch size is not in range of (1~%d)", DefaultNumOfMessages)
+	}
+	if err := checkWaitSeconds(o.waitSeconds); err != nil {
+		return nil, err
+	}
+
+	for i := len(o.middlewares) - 1; i >= 0; i-- {
+		if o.middlewares[i] != nil {
+			handler = o.middlewares[i](handler)
+		}
+	}
+
+	consumer := &Consumer{queue: queue, handler: handler, options: o, partitions: map[string]*partitionTurn{}}
+	if o.lease != nil {
+		leaseOptions := append([]LeaseOption{WithLeaseErrorHandler(consumer.reportError)}, o.lease...)
+		var err error
+		if consumer.leases, err = NewLeaseExtender(queue, leaseOptions...); err != nil {
+			return nil, err
+		}
+	}
+	return consumer, nil
+}
+
+// Run consumes messages until ctx is done and then waits for the in-flight handlers. A
+// pending long polling request is not interrupted, so Run may return up to the configured
+// wait seconds after ctx is done.
+func (p *Consumer) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		return fmt.Errorf("ali_mns: consumer is already running")
+	}
+	defer atomic.StoreInt32(&p.running, 0)
+
+	slots := make(chan struct{}, p.options.concurrency)
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		if p.options.acknowledger != nil {
+			p.options.acknowledger.FlushWait(context.Background())
+		}
+	}()
+
+	for {
+		free := acquireSlots(ctx, slots, int(p.options.batchSize))
+		if free == 0 {
+			return nil
+		}
+
+		messages, err := p.receive(ctx, int32(free))
+		for i := len(messages); i < free; i++ {
+			<-slots
+		}
+		if err != nil {
+			p.reportError(err)
+			if !sleepContext(ctx, p.options.errorDelay) {
+				return nil
+			}
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		p.dispatch(ctx, messages, slots, &wg)
+	}
+}
+
+func (p *Consumer) receive(ctx context.Context, numOfMessages int32) ([]MessageReceiveResponse, error) {
+	var resp BatchMessageReceiveResponse
+	var err error
+	if p.options.waitSeconds > 0 {
+		resp, err = batchReceiveMessage(p.queue, numOfMessages, p.options.waitSeconds)
+	} else {
+		resp, err = batchReceiveMessage(p.queue, numOfMessages)
+	}
+
+	if err == nil {
+		return resp.Messages, nil
+	}
+	if ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+		if p.options.waitSeconds == 0 {
+			// no long polling, avoid spinning on an empty queue
+			sleepContext(ctx, p.options.errorDelay)
+		}
+		return nil, nil
+	}
+
+	// messages whose body could not be decoded are left to reappear, the rest is handled
+	var decodeErr *MessageBodyDecodeError
+	if errors.As(err, &decodeErr) {
+		messages := []MessageReceiveResponse{}
+		for i, msg := range decodeErr.Messages {
+			if _, failed := decodeErr.Errors[i]; failed {
+				p.reportError(&ConsumerError{Message: msg, Err: decodeErr.Errors[i]})
+			} else {
+				messages = append(messages, msg)
+			}
+		}
+		return messages, nil
+	}
+	return nil, err
+}
+
+func (p *Consumer) dispatch(ctx context.Context, messages []MessageReceiveResponse, slots chan struct{}, wg *sync.WaitGroup) {
+	succeeded := make([]bool, len(messages))
+	var batchWg sync.WaitGroup
+
+	for i := range messages {
+		// turns are taken in receive order before any handler starts
+		prev, turn := p.enterPartition(messages[i])
+
+		wg.Add(1)
+		batchWg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer batchWg.Done()
+			defer func() { <-slots }()
+
+			if turn == nil {
+				succeeded[i] = p.process(ctx, &messages[i])
+			} else {
+				succeeded[i] = p.processInTurn(ctx, &messages[i], prev, turn)
+			}
+		}(i)
+	}
+
+	if !p.options.batchDelete {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		batchWg.Wait()
+
+		handles := []string{}
+		index := map[string]MessageReceiveResponse{}
+		for i, msg := range messages {
+			if succeeded[i] {
+				handles = append(handles, msg.ReceiptHandle)
+				index[msg.ReceiptHandle] = msg
+			}
+		}
+		if len(handles) == 0 {
+			return
+		}
+
+		resp, err := p.queue.BatchDeleteMessage(handles...)
+		if err == nil {
+			return
+		}
+		if len(resp.FailedMessages) == 0 {
+			p.reportError(err)
+			return
+		}
+		for _, entry := range resp.FailedMessages {
+			p.reportError(&ConsumerError{
+				Message: index[entry.ReceiptHandle],
+				Err:     fmt.Errorf("ali_mns: delete message failed, code: %s, message: %s", entry.ErrorCode, entry.ErrorMessage),
+			})
+		}
+	}()
+}
+
+// process runs the handler and, unless batch delete is enabled, deletes the message. It
+// reports whether the handler succeeded and updates the receipt handle of msg when its
+// lease was extended.
+func (p *Consumer) process(ctx context.Context, msg *MessageReceiveResponse) bool {
+	// MNSQueue forwards the intermediate hops of SendMessageAt when receiving, other
+	// AliMNSQueue implementations leave it to the consumer
+	if forwarded, err := ForwardScheduledMessage(p.queue, *msg); forwarded {
+		if err != nil {
+			p.reportError(&ConsumerError{Message: *msg, Err: err})
+		}
+		return false
+	}
+
+	policy := p.options.deadLetter
+	if policy != nil && policy.Exceeded(*msg) {
+		p.deadLetter(*msg, fmt.Sprintf("dequeue count %d exceeds %d", msg.DequeueCount, policy.MaxDequeueCount()))
+		return false
+	}
+
+	var err error
+	if p.leases != nil {
+		lease := p.leases.Track(*msg)
+		err = p.callHandler(ctx, *msg)
+		msg.ReceiptHandle = lease.Stop()
+	} else {
+		err = p.callHandler(ctx, *msg)
+	}
+	var markErr *DedupeMarkError
+	if errors.As(err, &markErr) {
+		// the message was handled, only its dedupe key is missing
+		p.reportError(&ConsumerError{Message: *msg, Err: err})
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(err, ErrDuplicateInFlight) {
+			// not a failure, the copy reappears after the original has been handled
+			return false
+		}
+		p.reportError(&ConsumerError{Message: *msg, Err: err})
+		if policy != nil && policy.Exhausted(*msg) {
+			p.deadLetter(*msg, err.Error())
+		} else if p.options.redelivery != nil {
+			if _, err = p.options.redelivery.Nack(p.queue, *msg); err != nil {
+				p.reportError(&ConsumerError{Message: *msg, Err: fmt.Errorf("ali_mns: schedule redelivery failed, %w", err)})
+			}
+		}
+		return false
+	}
+
+	switch {
+	case p.options.batchDelete:
+	case p.options.acknowledger != nil:
+		p.options.acknowledger.Ack(msg.ReceiptHandle)
+	default:
+		if err := p.queue.DeleteMessage(msg.ReceiptHandle); err != nil {
+			p.reportError(&ConsumerError{Message: *msg, Err: err})
+		}
+	}
+	return true
+}
+
+func (p *Consumer) deadLetter(msg MessageReceiveResponse, reason string) {
+	if err := p.options.deadLetter.DeadLetter(p.queue, msg, reason); err != nil {
+		p.reportError(&ConsumerError{Message: msg, Err: fmt.Errorf("ali_mns: dead-letter message failed, %w", err)})
+	}
+}
+
+func (p *Consumer) callHandler(ctx context.Context, msg MessageReceiveResponse) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("ali_mns: handler panic: %v", r)
+		}
+	}()
+	return p.handler(ctx, msg)
+}
+
+func (p *Consumer) reportError(err error) {
+	if p.options.errorHandler != nil {
+		p.options.errorHandler(err)
+	}
+}
+
+// acquireSlots blocks until at least one slot is free and then takes up to max slots. It
+// returns 0 when ctx is done.
+func acquireSlots(ctx context.Context, slots chan struct{}, max int) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0
+	}
+
+	free := 1
+	for free < max {
+		select {
+		case slots <- struct{}{}:
+			free++
+		default:
+			return free
+		}
+	}
+	return free
+}
+
+// sleepContext sleeps for d and reports false if ctx was done before.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
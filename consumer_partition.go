@@ -0,0 +1,72 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+)
+
+// partitionTurn is the place of a message in the queue of its partition key, done is
+// closed after the message completed.
+type partitionTurn struct {
+	key    string
+	done   chan struct{}
+	failed bool
+}
+
+// enterPartition appends msg to the turns of its partition key and returns the turn before
+// it, if any. Both are nil when partitioning is disabled or the key is empty.
+func (p *Consumer) enterPartition(msg MessageReceiveResponse) (prev, turn *partitionTurn) {
+	if p.options.partitionKey == nil {
+		return nil, nil
+	}
+	key := p.options.partitionKey(msg)
+	if key == "" {
+		return nil, nil
+	}
+
+	p.partitionLock.Lock()
+	defer p.partitionLock.Unlock()
+
+	turn = &partitionTurn{key: key, done: make(chan struct{})}
+	prev = p.partitions[key]
+	p.partitions[key] = turn
+	return prev, turn
+}
+
+func (p *Consumer) leavePartition(turn *partitionTurn) {
+	p.partitionLock.Lock()
+	defer p.partitionLock.Unlock()
+
+	close(turn.done)
+	if p.partitions[turn.key] == turn {
+		delete(p.partitions, turn.key)
+	}
+}
+
+// processInTurn waits for the previous message of the same key and processes msg unless
+// that one failed, in which case msg is left to reappear so that the order is kept. With
+// lease extension msg stays invisible while it waits.
+func (p *Consumer) processInTurn(ctx context.Context, msg *MessageReceiveResponse, prev, turn *partitionTurn) bool {
+	defer p.leavePartition(turn)
+
+	if prev != nil {
+		if p.leases != nil {
+			lease := p.leases.Track(*msg)
+			<-prev.done
+			lease.stopAndUpdate(msg)
+		} else {
+			<-prev.done
+		}
+		if prev.failed {
+			turn.failed = true
+			p.reportError(&ConsumerError{
+				Message: *msg,
+				Err:     fmt.Errorf("ali_mns: skipped, an earlier message of partition %s failed", turn.key),
+			})
+			return false
+		}
+	}
+
+	turn.failed = !p.process(ctx, msg)
+	return !turn.failed
+}
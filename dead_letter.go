@@ -0,0 +1,124 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// User properties set on messages forwarded to a dead-letter queue.
+const (
+	DeadLetterOriginalMessageIdProperty = "mns-dlq-original-message-id"
+	DeadLetterSourceQueueProperty       = "mns-dlq-source-queue"
+	DeadLetterFailureReasonProperty     = "mns-dlq-failure-reason"
+	DeadLetterDequeueCountProperty      = "mns-dlq-dequeue-count"
+
+	maxDeadLetterReasonLength = 1024
+)
+
+// DeadLetterPolicy moves messages which were received too often to a dead-letter queue.
+type DeadLetterPolicy struct {
+	queue           AliMNSQueue
+	maxDequeueCount int64
+}
+
+// NewDeadLetterPolicy creates a policy that dead-letters a message once its handler failed
+// on the maxDequeueCount-th delivery, or when it is received more often than that.
+func NewDeadLetterPolicy(deadLetterQueue AliMNSQueue, maxDequeueCount int64) (*DeadLetterPolicy, error) {
+	if deadLetterQueue == nil {
+		return nil, fmt.Errorf("ali_mns: dead-letter queue could not be nil")
+	}
+	if maxDequeueCount <= 0 {
+		return nil, fmt.Errorf("ali_mns: max dequeue count must be positive")
+	}
+	return &DeadLetterPolicy{queue: deadLetterQueue, maxDequeueCount: maxDequeueCount}, nil
+}
+
+func (p *DeadLetterPolicy) Queue() AliMNSQueue {
+	return p.queue
+}
+
+func (p *DeadLetterPolicy) MaxDequeueCount() int64 {
+	return p.maxDequeueCount
+}
+
+// Exceeded reports whether msg has been received more than the max dequeue count.
+func (p *DeadLetterPolicy) Exceeded(msg MessageReceiveResponse) bool {
+	return msg.DequeueCount > p.maxDequeueCount
+}
+
+// Exhausted reports whether msg is on its last allowed delivery.
+func (p *DeadLetterPolicy) Exhausted(msg MessageReceiveResponse) bool {
+	return msg.DequeueCount >= p.maxDequeueCount
+}
+
+// DeadLetter sends msg to the dead-letter queue together with its original message id and
+// the failure reason, then deletes it from source.
+func (p *DeadLetterPolicy) DeadLetter(source AliMNSQueue, msg MessageReceiveResponse, reason string) (err error) {
+	if len(reason) > maxDeadLetterReasonLength {
+		reason = reason[:maxDeadLetterReasonLength]
+	}
+
+	request := MessageSendRequest{
+		MessageBody:    msg.MessageBody,
+		Priority:       msg.Priority,
+		UserProperties: append(MessageProperties{}, msg.UserProperties...),
+	}
+	request.UserProperties.Set(DeadLetterOriginalMessageIdProperty, msg.MessageId)
+	request.UserProperties.Set(DeadLetterSourceQueueProperty, source.Name())
+	request.UserProperties.Set(DeadLetterFailureReasonProperty, reason)
+	request.UserProperties.SetTyped(DeadLetterDequeueCountProperty, strconv.FormatInt(msg.DequeueCount, 10), NUMBER_PROPERTY)
+
+	if _, err = p.queue.SendMessage(request); err != nil {
+		return
+	}
+	return source.DeleteMessage(msg.ReceiptHandle)
+}
+
+// RedriveDeadLetters moves up to maxMessages messages (all when maxMessages <= 0) from a
+// dead-letter queue back to target, removing the dead-letter properties. Only messages
+// which were dead-lettered from target are moved when onlyFromTarget is set, the others
+// stay invisible in the dead-letter queue until their visibility timeout.
+func RedriveDeadLetters(ctx context.Context, deadLetterQueue AliMNSQueue, target AliMNSQueue, maxMessages int, onlyFromTarget bool) (moved int, err error) {
+	for maxMessages <= 0 || moved < maxMessages {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		num := DefaultNumOfMessages
+		if maxMessages > 0 && int32(maxMessages-moved) < num {
+			num = int32(maxMessages - moved)
+		}
+
+		var resp BatchMessageReceiveResponse
+		if resp, err = batchReceiveMessage(deadLetterQueue, num); err != nil {
+			if ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+				err = nil
+			}
+			return
+		}
+
+		for _, msg := range resp.Messages {
+			if source, _ := msg.UserProperties.Get(DeadLetterSourceQueueProperty); onlyFromTarget && source != target.Name() {
+				continue
+			}
+
+			request := MessageSendRequest{MessageBody: msg.MessageBody, Priority: msg.Priority}
+			for _, property := range msg.UserProperties {
+				if !strings.HasPrefix(property.Name, "mns-dlq-") {
+					request.UserProperties = append(request.UserProperties, property)
+				}
+			}
+
+			if _, err = target.SendMessage(request); err != nil {
+				return
+			}
+			if err = deadLetterQueue.DeleteMessage(msg.ReceiptHandle); err != nil {
+				return
+			}
+			moved++
+		}
+	}
+	return
+}
@@ -0,0 +1,189 @@
+package ali_mns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultDedupeTTL time.Duration = time.Hour
+)
+
+// ErrDuplicateInFlight is returned by a Deduplicator for a message whose key is being
+// handled by another copy. A Consumer leaves such a message to reappear without counting
+// the attempt as a failure.
+var ErrDuplicateInFlight = errors.New("ali_mns: duplicate message is being handled")
+
+// DedupeMarkError is returned by a Deduplicator when the message was handled but its key
+// could not be recorded. A Consumer reports it and still deletes the message, since
+// handling it again is the duplicate the deduplicator should prevent.
+type DedupeMarkError struct {
+	Key string
+	Err error
+}
+
+func (e *DedupeMarkError) Error() string {
+	return fmt.Sprintf("ali_mns: mark dedupe key %s failed, %v", e.Key, e.Err)
+}
+
+func (e *DedupeMarkError) Unwrap() error {
+	return e.Err
+}
+
+// DedupeStore records the keys of processed messages. Implementations must be safe for
+// concurrent use.
+type DedupeStore interface {
+	// Seen reports whether key was marked and has not expired.
+	Seen(key string) (bool, error)
+	// Mark records key for ttl.
+	Mark(key string, ttl time.Duration) error
+}
+
+// MemoryDedupeStore keeps keys in memory, expired keys are pruned at most once a minute.
+type MemoryDedupeStore struct {
+	lock      sync.Mutex
+	keys      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewMemoryDedupeStore() *MemoryDedupeStore {
+	return &MemoryDedupeStore{keys: map[string]time.Time{}, lastPrune: time.Now()}
+}
+
+func (p *MemoryDedupeStore) Seen(key string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	expire, ok := p.keys[key]
+	return ok && time.Now().Before(expire), nil
+}
+
+func (p *MemoryDedupeStore) Mark(key string, ttl time.Duration) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	p.keys[key] = now.Add(ttl)
+	if now.Sub(p.lastPrune) >= time.Minute {
+		for k, expire := range p.keys {
+			if !now.Before(expire) {
+				delete(p.keys, k)
+			}
+		}
+		p.lastPrune = now
+	}
+	return nil
+}
+
+// Deduplicator is a Middleware that handles every message key once within a TTL. A
+// duplicate of an already handled message is dropped by returning nil, so that a Consumer
+// deletes it. A duplicate arriving while the first copy is still being handled fails with
+// ErrDuplicateInFlight, so it reappears later and is dropped then or handled if the first
+// copy failed.
+type Deduplicator struct {
+	store   DedupeStore
+	ttl     time.Duration
+	keyFunc func(msg MessageReceiveResponse) (string, error)
+
+	lock     sync.Mutex
+	inflight map[string]bool
+	dropped  int64
+}
+
+type DedupeOption func(*Deduplicator)
+
+// WithDedupeKey replaces the MessageId as the dedupe key, e.g. with a business id taken
+// from the body.
+func WithDedupeKey(keyFunc func(msg MessageReceiveResponse) (string, error)) DedupeOption {
+	return func(d *Deduplicator) {
+		if keyFunc != nil {
+			d.keyFunc = keyFunc
+		}
+	}
+}
+
+// NewDeduplicator creates a deduplicator, a store of nil uses a MemoryDedupeStore and a
+// ttl not positive uses DefaultDedupeTTL.
+func NewDeduplicator(store DedupeStore, ttl time.Duration, options ...DedupeOption) *Deduplicator {
+	if store == nil {
+		store = NewMemoryDedupeStore()
+	}
+	if ttl <= 0 {
+		ttl = DefaultDedupeTTL
+	}
+
+	d := &Deduplicator{
+		store:    store,
+		ttl:      ttl,
+		keyFunc:  func(msg MessageReceiveResponse) (string, error) { return msg.MessageId, nil },
+		inflight: map[string]bool{},
+	}
+	for _, option := range options {
+		if option != nil {
+			option(d)
+		}
+	}
+	return d
+}
+
+// Dropped returns how many duplicates were dropped.
+func (p *Deduplicator) Dropped() int64 {
+	return atomic.LoadInt64(&p.dropped)
+}
+
+// Middleware returns the deduplicator as a Middleware.
+func (p *Deduplicator) Middleware() Middleware {
+	return p.Wrap
+}
+
+func (p *Deduplicator) Wrap(next Handler) Handler {
+	return func(ctx context.Context, msg MessageReceiveResponse) error {
+		key, err := p.keyFunc(msg)
+		if err != nil {
+			return fmt.Errorf("ali_mns: extract dedupe key failed, %w", err)
+		}
+
+		if !p.begin(key) {
+			return fmt.Errorf("%w, dedupe key: %s", ErrDuplicateInFlight, key)
+		}
+		defer p.end(key)
+
+		seen, err := p.store.Seen(key)
+		if err != nil {
+			return err
+		}
+		if seen {
+			atomic.AddInt64(&p.dropped, 1)
+			return nil
+		}
+
+		if err = next(ctx, msg); err != nil {
+			return err
+		}
+		if err = p.store.Mark(key, p.ttl); err != nil {
+			return &DedupeMarkError{Key: key, Err: err}
+		}
+		return nil
+	}
+}
+
+func (p *Deduplicator) begin(key string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.inflight[key] {
+		return false
+	}
+	p.inflight[key] = true
+	return true
+}
+
+func (p *Deduplicator) end(key string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.inflight, key)
+}
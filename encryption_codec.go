@@ -0,0 +1,201 @@
+package ali_mns
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	neturl "net/url"
+)
+
+const (
+	EncryptionEnvelopeKind = "aes-gcm"
+
+	dataKeySize = 32
+)
+
+// KeyProvider creates and unwraps the data keys used by EncryptionCodec. Implementations
+// typically delegate to a key management service.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key, both in plaintext and wrapped by the key
+	// identified by keyId.
+	GenerateDataKey() (keyId string, plaintext []byte, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key which was wrapped by the key identified by keyId.
+	DecryptDataKey(keyId string, wrapped []byte) (plaintext []byte, err error)
+}
+
+// DecryptionError is returned when a received body can not be decrypted, e.g. because
+// its key is unknown to the KeyProvider or the body has been tampered with.
+type DecryptionError struct {
+	KeyId string
+	Err   error
+}
+
+func (e *DecryptionError) Error() string {
+	return fmt.Sprintf("ali_mns: decrypt message body failed, key id: %s, error: %v", e.KeyId, e.Err)
+}
+
+func (e *DecryptionError) Unwrap() error {
+	return e.Err
+}
+
+// EncryptionCodec encrypts bodies with AES-GCM using a fresh data key per message. The
+// data key is stored in the body header wrapped by the KeyProvider together with the id
+// of the wrapping key, so keys can be rotated while old messages are still in flight.
+type EncryptionCodec struct {
+	provider KeyProvider
+}
+
+func NewEncryptionCodec(provider KeyProvider) *EncryptionCodec {
+	return &EncryptionCodec{provider: provider}
+}
+
+func (p *EncryptionCodec) EncodeBody(body string) (string, error) {
+	keyId, dataKey, wrapped, err := p.provider.GenerateDataKey()
+	if err != nil {
+		return "", newEncodeBodyError(EncryptionEnvelopeKind, err)
+	}
+
+	nonce, ciphertext, err := sealAESGCM(dataKey, []byte(body), []byte(keyId))
+	if err != nil {
+		return "", newEncodeBodyError(EncryptionEnvelopeKind, err)
+	}
+
+	return messageEnvelope{
+		kind: EncryptionEnvelopeKind,
+		headers: neturl.Values{
+			"kid":   {keyId},
+			"key":   {base64.StdEncoding.EncodeToString(wrapped)},
+			"nonce": {base64.StdEncoding.EncodeToString(nonce)},
+		},
+		payload: base64.StdEncoding.EncodeToString(ciphertext),
+	}.String(), nil
+}
+
+func (p *EncryptionCodec) DecodeBody(body string) (string, error) {
+	env, ok := parseMessageEnvelope(body)
+	if !ok || env.kind != EncryptionEnvelopeKind {
+		return body, nil
+	}
+
+	keyId := env.headers.Get("kid")
+	wrapped, err := base64.StdEncoding.DecodeString(env.headers.Get("key"))
+	if err != nil {
+		return "", &DecryptionError{KeyId: keyId, Err: err}
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.headers.Get("nonce"))
+	if err != nil {
+		return "", &DecryptionError{KeyId: keyId, Err: err}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.payload)
+	if err != nil {
+		return "", &DecryptionError{KeyId: keyId, Err: err}
+	}
+
+	dataKey, err := p.provider.DecryptDataKey(keyId, wrapped)
+	if err != nil {
+		return "", &DecryptionError{KeyId: keyId, Err: err}
+	}
+
+	plaintext, err := openAESGCM(dataKey, nonce, ciphertext, []byte(keyId))
+	if err != nil {
+		return "", &DecryptionError{KeyId: keyId, Err: err}
+	}
+	return string(plaintext), nil
+}
+
+// IsEncryptedMessageBody reports whether body was encrypted by an EncryptionCodec.
+func IsEncryptedMessageBody(body string) bool {
+	kind, ok := MessageBodyEnvelopeKind(body)
+	return ok && kind == EncryptionEnvelopeKind
+}
+
+// StaticKeyProvider wraps data keys with AES-GCM master keys held in memory. New data keys
+// are wrapped by the current key, the other keys are only used to unwrap.
+type StaticKeyProvider struct {
+	currentKeyId string
+	keys         map[string][]byte
+}
+
+func NewStaticKeyProvider(currentKeyId string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	if _, exist := keys[currentKeyId]; !exist {
+		return nil, fmt.Errorf("ali_mns: current key %q not found", currentKeyId)
+	}
+
+	provider := &StaticKeyProvider{currentKeyId: currentKeyId, keys: map[string][]byte{}}
+	for keyId, key := range keys {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("ali_mns: invalid length %d of key %q, must be 16, 24 or 32", len(key), keyId)
+		}
+		provider.keys[keyId] = append([]byte{}, key...)
+	}
+	return provider, nil
+}
+
+func (p *StaticKeyProvider) GenerateDataKey() (keyId string, plaintext []byte, wrapped []byte, err error) {
+	plaintext = make([]byte, dataKeySize)
+	if _, err = rand.Read(plaintext); err != nil {
+		return
+	}
+
+	nonce, ciphertext, err := sealAESGCM(p.keys[p.currentKeyId], plaintext, []byte(p.currentKeyId))
+	if err != nil {
+		return
+	}
+	return p.currentKeyId, plaintext, append(nonce, ciphertext...), nil
+}
+
+func (p *StaticKeyProvider) DecryptDataKey(keyId string, wrapped []byte) (plaintext []byte, err error) {
+	key, exist := p.keys[keyId]
+	if !exist {
+		return nil, fmt.Errorf("ali_mns: unknown key %q", keyId)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ali_mns: wrapped data key is too short")
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], []byte(keyId))
+}
+
+func sealAESGCM(key []byte, plaintext []byte, additionalData []byte) (nonce []byte, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func openAESGCM(key []byte, nonce []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("ali_mns: invalid nonce size %d", len(nonce))
+	}
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
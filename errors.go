@@ -76,4 +76,9 @@ var (
 	ERR_MNS_RET_NUMBER_RANGE_ERROR                 = errors.TN(ALI_MNS_ERR_NS, 132, "list param of ret number is not in range of (1~1000)")
 	ERR_MNS_QUEUE_ALREADY_EXIST_AND_HAVE_SAME_ATTR = errors.TN(ALI_MNS_ERR_NS, 133, "mns queue already exist, and the attribute is the same, queue name: {{.name}}")
 	ERR_MNS_BATCH_OP_FAIL                          = errors.TN(ALI_MNS_ERR_NS, 136, "mns queue batch operation fail")
+
+	ERR_MNS_ENCODE_MESSAGE_BODY_FAILED = errors.TN(ALI_MNS_ERR_NS, 300, "encode message body failed, codec: {{.codec}}, error: {{.err}}")
+	ERR_MNS_DECODE_MESSAGE_BODY_FAILED = errors.TN(ALI_MNS_ERR_NS, 301, "decode message body failed, codec: {{.codec}}, error: {{.err}}")
+	ERR_MNS_INVALID_MESSAGE_PROPERTY   = errors.TN(ALI_MNS_ERR_NS, 302, "invalid message property, name: {{.name}}, reason: {{.reason}}")
+	ERR_MNS_ASYNC_QUEUE_FULL           = errors.TN(ALI_MNS_ERR_NS, 303, "async send queue is full, size: {{.size}}")
 )
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
@@ -32,8 +31,11 @@ func main() {
 		return
 	}
 	messageBody := "hello <\"aliyun-mns-go-sdk\">"
+	// Messages produced by the Java/Python SDKs are base64 encoded, the queue encodes
+	// and decodes bodies transparently when BASE64_ENCODING is set.
+	encoding := ali_mns.RAW_ENCODING
 	if isBase64 {
-		messageBody = base64.StdEncoding.EncodeToString([]byte(messageBody))
+		encoding = ali_mns.BASE64_ENCODING
 	}
 
 	msg := ali_mns.MessageSendRequest{
@@ -50,7 +52,7 @@ func main() {
 		return
 	}
 
- queue, e := ali_mns.NewMNSQueue(queueName, client)
+ queue, e := ali_mns.NewMNSQueueWithOptions(queueName, client, ali_mns.WithMessageBodyEncoding(encoding))
  	if e != nil {
  		fmt.Println(e)
  		return
@@ -76,17 +78,7 @@ func main() {
 			case resp := <-respChan:
 				{
 					logs.Pretty("response: ", resp)
-					if isBase64 {
-						decodedBytes, err := base64.StdEncoding.DecodeString(resp.MessageBody)
-						if err != nil {
-							fmt.Println("Error decoding Base64:", err)
-							return
-						}
-
-						logs.Pretty("message: ", string(decodedBytes))
-					} else {
-						logs.Pretty("message: ", resp.MessageBody)
-					}
+					logs.Pretty("message: ", resp.MessageBody)
 
 					logs.Debug("change the visibility: ", resp.ReceiptHandle)
 					if ret, e := queue.ChangeMessageVisibility(resp.ReceiptHandle, 5); e != nil {
@@ -0,0 +1,107 @@
+package ali_mns
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+const (
+	GzipEnvelopeKind = "gzip"
+
+	DefaultGzipThreshold      int = 1024
+	DefaultGzipMaxDecodedSize int = 16 << 20
+)
+
+// GzipBodyCodec compresses bodies of at least threshold bytes with gzip and wraps the
+// base64 encoded result in a self-describing envelope. Bodies which would not shrink are
+// sent unchanged. Received bodies which decompress to more than the max decoded size,
+// DefaultGzipMaxDecodedSize unless set, fail to decode.
+type GzipBodyCodec struct {
+	threshold      int
+	level          int
+	maxDecodedSize int
+}
+
+func NewGzipBodyCodec(threshold int, level ...int) *GzipBodyCodec {
+	if threshold <= 0 {
+		threshold = DefaultGzipThreshold
+	}
+
+	codec := &GzipBodyCodec{threshold: threshold, level: gzip.DefaultCompression, maxDecodedSize: DefaultGzipMaxDecodedSize}
+	if len(level) == 1 {
+		codec.level = level[0]
+	}
+	return codec
+}
+
+// SetMaxDecodedSize limits how many bytes a received body may decompress to, which guards
+// against decompression bombs. Raise it when large bodies are offloaded by a
+// ClaimCheckCodec after compression. It must be called before the codec is used.
+func (p *GzipBodyCodec) SetMaxDecodedSize(size int) {
+	if size > 0 {
+		p.maxDecodedSize = size
+	}
+}
+
+func (p *GzipBodyCodec) EncodeBody(body string) (string, error) {
+	if len(body) < p.threshold {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, p.level)
+	if err != nil {
+		return "", newEncodeBodyError(GzipEnvelopeKind, err)
+	}
+	if _, err = writer.Write([]byte(body)); err != nil {
+		return "", newEncodeBodyError(GzipEnvelopeKind, err)
+	}
+	if err = writer.Close(); err != nil {
+		return "", newEncodeBodyError(GzipEnvelopeKind, err)
+	}
+
+	encoded := messageEnvelope{
+		kind:    GzipEnvelopeKind,
+		payload: base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}.String()
+	if len(encoded) >= len(body) {
+		return body, nil
+	}
+	return encoded, nil
+}
+
+func (p *GzipBodyCodec) DecodeBody(body string) (string, error) {
+	env, ok := parseMessageEnvelope(body)
+	if !ok || env.kind != GzipEnvelopeKind {
+		return body, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(env.payload)
+	if err != nil {
+		return "", newDecodeBodyError(GzipEnvelopeKind, err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", newDecodeBodyError(GzipEnvelopeKind, err)
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(p.maxDecodedSize)+1))
+	if err != nil {
+		return "", newDecodeBodyError(GzipEnvelopeKind, err)
+	}
+	if len(decompressed) > p.maxDecodedSize {
+		return "", newDecodeBodyError(GzipEnvelopeKind, fmt.Errorf("decompressed body exceeds %d bytes", p.maxDecodedSize))
+	}
+	return string(decompressed), nil
+}
+
+// IsGzipMessageBody reports whether body was compressed by a GzipBodyCodec.
+func IsGzipMessageBody(body string) bool {
+	kind, ok := MessageBodyEnvelopeKind(body)
+	return ok && kind == GzipEnvelopeKind
+}
@@ -0,0 +1,272 @@
+package ali_mns
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIdempotencyStoreCapacity int = 10000
+
+	// fileIdempotencyCompactLines is the least number of writes between two prunes of a
+	// FileIdempotencyStore.
+	fileIdempotencyCompactLines int = 256
+)
+
+// IdempotencyStore records the response of a sent message under its idempotency key.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the response recorded for key, ok is false when there is none or it
+	// has expired.
+	Get(key string) (resp MessageSendResponse, ok bool, err error)
+	// Put records resp for key until expire.
+	Put(key string, resp MessageSendResponse, expire time.Time) error
+}
+
+type idempotencyRecord struct {
+	Key      string              `json:"key"`
+	Response MessageSendResponse `json:"response"`
+	Expire   time.Time           `json:"expire"`
+}
+
+// MemoryIdempotencyStore keeps at most capacity records in memory, evicting the least
+// recently used one when full.
+type MemoryIdempotencyStore struct {
+	capacity int
+
+	lock    sync.Mutex
+	records map[string]*list.Element
+	order   *list.List
+}
+
+func NewMemoryIdempotencyStore(capacity int) *MemoryIdempotencyStore {
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyStoreCapacity
+	}
+	return &MemoryIdempotencyStore{
+		capacity: capacity,
+		records:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (p *MemoryIdempotencyStore) Get(key string) (resp MessageSendResponse, ok bool, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	element, exist := p.records[key]
+	if !exist {
+		return
+	}
+	record := element.Value.(*idempotencyRecord)
+	if !time.Now().Before(record.Expire) {
+		p.order.Remove(element)
+		delete(p.records, key)
+		return
+	}
+	p.order.MoveToFront(element)
+	return record.Response, true, nil
+}
+
+func (p *MemoryIdempotencyStore) Put(key string, resp MessageSendResponse, expire time.Time) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if element, exist := p.records[key]; exist {
+		element.Value = &idempotencyRecord{Key: key, Response: resp, Expire: expire}
+		p.order.MoveToFront(element)
+		return nil
+	}
+
+	p.records[key] = p.order.PushFront(&idempotencyRecord{Key: key, Response: resp, Expire: expire})
+	for p.order.Len() > p.capacity {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.records, oldest.Value.(*idempotencyRecord).Key)
+	}
+	return nil
+}
+
+// FileIdempotencyStore keeps records in memory and appends them to a file as JSON lines so
+// that they survive a restart. Expired records are dropped when read and periodically while
+// writing, and the file is rewritten with the live records when it is opened and whenever
+// its dead lines outnumber the live ones.
+type FileIdempotencyStore struct {
+	path string
+
+	lock    sync.Mutex
+	file    *os.File
+	records map[string]idempotencyRecord
+	// lines counts the lines of the file, expired records are pruned and the file is
+	// compacted once it reaches nextCheck
+	lines     int
+	nextCheck int
+}
+
+func NewFileIdempotencyStore(path string) (*FileIdempotencyStore, error) {
+	store := &FileIdempotencyStore{path: path, records: map[string]idempotencyRecord{}}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	if err := store.compact(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (p *FileIdempotencyStore) Get(key string) (resp MessageSendResponse, ok bool, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	record, exist := p.records[key]
+	if !exist {
+		return
+	}
+	if !time.Now().Before(record.Expire) {
+		delete(p.records, key)
+		return
+	}
+	return record.Response, true, nil
+}
+
+func (p *FileIdempotencyStore) Put(key string, resp MessageSendResponse, expire time.Time) error {
+	record := idempotencyRecord{Key: key, Response: resp, Expire: expire}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		return fmt.Errorf("ali_mns: idempotency store %s is closed", p.path)
+	}
+	if _, err = p.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = p.file.Sync(); err != nil {
+		return err
+	}
+	p.records[key] = record
+	p.lines++
+
+	if p.lines >= p.nextCheck {
+		p.pruneLocked()
+	}
+	return nil
+}
+
+// pruneLocked drops the expired records and compacts the file when its dead lines
+// outnumber the live ones. The next check is scheduled after as many writes as there are
+// live records, so the scans cost a constant amount per Put.
+func (p *FileIdempotencyStore) pruneLocked() {
+	now := time.Now()
+	for key, record := range p.records {
+		if !now.Before(record.Expire) {
+			delete(p.records, key)
+		}
+	}
+	if p.lines > 2*len(p.records) {
+		// the records are durable already, a failed compaction is retried later
+		p.compact()
+	}
+	p.scheduleCheck()
+}
+
+func (p *FileIdempotencyStore) scheduleCheck() {
+	interval := len(p.records)
+	if interval < fileIdempotencyCompactLines {
+		interval = fileIdempotencyCompactLines
+	}
+	p.nextCheck = p.lines + interval
+}
+
+// Close closes the underlying file, later Put calls fail.
+func (p *FileIdempotencyStore) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
+
+func (p *FileIdempotencyStore) load() error {
+	file, err := os.Open(p.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		record := idempotencyRecord{}
+		// a torn last line after a crash is skipped
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if now.Before(record.Expire) {
+			p.records[record.Key] = record
+		} else {
+			delete(p.records, record.Key)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with the live records only and opens it for appending.
+func (p *FileIdempotencyStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	now := time.Now()
+	lines := 0
+	writer := bufio.NewWriter(tmp)
+	for _, record := range p.records {
+		if !now.Before(record.Expire) {
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+		lines++
+	}
+	if err = writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), p.path); err != nil {
+		return err
+	}
+
+	if p.file != nil {
+		p.file.Close()
+	}
+	p.lines = lines
+	p.scheduleCheck()
+	p.file, err = os.OpenFile(p.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	return err
+}
@@ -0,0 +1,101 @@
+package ali_mns
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIdempotencyWindow time.Duration = 10 * time.Minute
+)
+
+// IdempotentQueue sends messages at most once per idempotency key within a window. A
+// repeated send returns the response of the first successful one without sending again.
+type IdempotentQueue struct {
+	queue  AliMNSQueue
+	store  IdempotencyStore
+	window time.Duration
+
+	lock     sync.Mutex
+	inflight map[string]*sync.Mutex
+	waiters  map[string]int
+}
+
+// NewIdempotentQueue wraps queue, a store of nil uses a MemoryIdempotencyStore and a
+// window not positive uses DefaultIdempotencyWindow.
+func NewIdempotentQueue(queue AliMNSQueue, store IdempotencyStore, window time.Duration) (*IdempotentQueue, error) {
+	if queue == nil {
+		return nil, fmt.Errorf("ali_mns: idempotent queue could not be nil")
+	}
+	if store == nil {
+		store = NewMemoryIdempotencyStore(DefaultIdempotencyStoreCapacity)
+	}
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+
+	return &IdempotentQueue{
+		queue:    queue,
+		store:    store,
+		window:   window,
+		inflight: map[string]*sync.Mutex{},
+		waiters:  map[string]int{},
+	}, nil
+}
+
+func (p *IdempotentQueue) Queue() AliMNSQueue {
+	return p.queue
+}
+
+// SendMessage sends message unless a message with the same key was sent within the
+// window. When the message was sent but the key could not be recorded, resp is valid and
+// err reports the store failure.
+func (p *IdempotentQueue) SendMessage(idempotencyKey string, message MessageSendRequest) (resp MessageSendResponse, err error) {
+	if idempotencyKey == "" {
+		err = fmt.Errorf("ali_mns: idempotency key could not be empty")
+		return
+	}
+
+	// sends with the same key are serialized so that a concurrent retry waits for the
+	// first attempt instead of sending a duplicate
+	unlock := p.lockKey(idempotencyKey)
+	defer unlock()
+
+	var ok bool
+	if resp, ok, err = p.store.Get(idempotencyKey); err != nil || ok {
+		return
+	}
+
+	if resp, err = p.queue.SendMessage(message); err != nil {
+		return
+	}
+
+	if putErr := p.store.Put(idempotencyKey, resp, time.Now().Add(p.window)); putErr != nil {
+		err = fmt.Errorf("ali_mns: message %s sent but idempotency key not recorded, %w", resp.MessageId, putErr)
+	}
+	return
+}
+
+func (p *IdempotentQueue) lockKey(key string) (unlock func()) {
+	p.lock.Lock()
+	keyLock, exist := p.inflight[key]
+	if !exist {
+		keyLock = &sync.Mutex{}
+		p.inflight[key] = keyLock
+	}
+	p.waiters[key]++
+	p.lock.Unlock()
+
+	keyLock.Lock()
+	return func() {
+		keyLock.Unlock()
+
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.waiters[key]--; p.waiters[key] == 0 {
+			delete(p.waiters, key)
+			delete(p.inflight, key)
+		}
+	}
+}
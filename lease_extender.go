@@ -0,0 +1,231 @@
+package ali_mns
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLeaseVisibilityTimeout int64         = 30
+	DefaultLeaseMaxAge            time.Duration = time.Hour
+	DefaultLeaseRetryDelay        time.Duration = time.Second
+
+	minLeaseExtendDelay = 100 * time.Millisecond
+)
+
+type LeaseOptions struct {
+	visibilityTimeout int64
+	maxAge            time.Duration
+	retryDelay        time.Duration
+	errorHandler      func(err error)
+}
+
+type LeaseOption func(*LeaseOptions)
+
+// WithLeaseVisibilityTimeout sets the visibility timeout in seconds requested on every
+// extension, 1~43200.
+func WithLeaseVisibilityTimeout(visibilityTimeout int64) LeaseOption {
+	return func(o *LeaseOptions) {
+		o.visibilityTimeout = visibilityTimeout
+	}
+}
+
+// WithLeaseMaxAge sets how long after being tracked a message is kept invisible at most,
+// after that its lease is no longer extended and the message reappears in the queue.
+func WithLeaseMaxAge(maxAge time.Duration) LeaseOption {
+	return func(o *LeaseOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithLeaseRetryDelay sets how long to wait before retrying a failed extension.
+func WithLeaseRetryDelay(delay time.Duration) LeaseOption {
+	return func(o *LeaseOptions) {
+		o.retryDelay = delay
+	}
+}
+
+// WithLeaseErrorHandler sets the callback for failed extensions.
+func WithLeaseErrorHandler(handler func(err error)) LeaseOption {
+	return func(o *LeaseOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// LeaseExtender keeps received messages invisible while they are being processed by
+// periodically calling ChangeMessageVisibility on them.
+type LeaseExtender struct {
+	queue   AliMNSQueue
+	options LeaseOptions
+}
+
+func NewLeaseExtender(queue AliMNSQueue, options ...LeaseOption) (*LeaseExtender, error) {
+	if queue == nil {
+		return nil, fmt.Errorf("ali_mns: lease extender queue could not be nil")
+	}
+
+	o := LeaseOptions{
+		visibilityTimeout: DefaultLeaseVisibilityTimeout,
+		maxAge:            DefaultLeaseMaxAge,
+		retryDelay:        DefaultLeaseRetryDelay,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if err := checkVisibilityTimeout(int32(o.visibilityTimeout)); err != nil {
+		return nil, err
+	}
+	if o.maxAge <= 0 {
+		return nil, fmt.Errorf("ali_mns: lease max age must be positive")
+	}
+
+	return &LeaseExtender{queue: queue, options: o}, nil
+}
+
+// Track starts extending the visibility of msg until the returned lease is stopped or its
+// max age is reached.
+func (p *LeaseExtender) Track(msg MessageReceiveResponse) *Lease {
+	lease := &Lease{
+		extender:        p,
+		messageId:       msg.MessageId,
+		receiptHandle:   msg.ReceiptHandle,
+		nextVisibleTime: msg.NextVisibleTime,
+		deadline:        time.Now().Add(p.options.maxAge),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	go lease.run()
+	return lease
+}
+
+// Lease is the visibility lease of one in-flight message. Its receipt handle changes on
+// every extension, so the message must be deleted with the handle returned by Stop.
+type Lease struct {
+	extender  *LeaseExtender
+	messageId string
+	deadline  time.Time
+
+	lock            sync.Mutex
+	receiptHandle   string
+	nextVisibleTime int64
+	expired         bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// ReceiptHandle returns the current receipt handle of the message.
+func (p *Lease) ReceiptHandle() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.receiptHandle
+}
+
+// Expired reports whether the lease stopped being extended before Stop was called, either
+// because the max age was reached or the receipt handle became invalid.
+func (p *Lease) Expired() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.expired
+}
+
+// Stop ends the extension and returns the latest receipt handle. It waits for an
+// extension in progress so that the returned handle is never outdated.
+func (p *Lease) Stop() string {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+	return p.ReceiptHandle()
+}
+
+// stopAndUpdate stops the lease and moves its receipt handle and next visible time to msg.
+func (p *Lease) stopAndUpdate(msg *MessageReceiveResponse) {
+	msg.ReceiptHandle = p.Stop()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.nextVisibleTime > 0 {
+		msg.NextVisibleTime = p.nextVisibleTime
+	}
+}
+
+func (p *Lease) run() {
+	defer close(p.done)
+
+	timer := time.NewTimer(p.nextDelay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-timer.C:
+		}
+
+		if !time.Now().Add(time.Duration(p.extender.options.visibilityTimeout) * time.Second).Before(p.deadline) {
+			p.expire()
+			return
+		}
+
+		if retry, ok := p.extend(); !ok {
+			return
+		} else if retry {
+			timer.Reset(p.extender.options.retryDelay)
+		} else {
+			timer.Reset(p.nextDelay())
+		}
+	}
+}
+
+// extend changes the visibility once. It reports whether the extension should be retried
+// and whether the lease is still alive.
+func (p *Lease) extend() (retry bool, alive bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	resp, err := p.extender.queue.ChangeMessageVisibility(p.receiptHandle, p.extender.options.visibilityTimeout)
+	if err == nil {
+		p.receiptHandle = resp.ReceiptHandle
+		p.nextVisibleTime = resp.NextVisibleTime
+		return false, true
+	}
+
+	p.extender.reportError(fmt.Errorf("ali_mns: extend visibility of message %s failed, %w", p.messageId, err))
+	if ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) || ERR_MNS_RECEIPT_HANDLE_ERROR.IsEqual(err) {
+		p.expired = true
+		return false, false
+	}
+	return true, true
+}
+
+func (p *Lease) expire() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.expired = true
+}
+
+// nextDelay extends the lease when half of the remaining visibility time has passed.
+func (p *Lease) nextDelay() time.Duration {
+	p.lock.Lock()
+	nextVisibleTime := p.nextVisibleTime
+	p.lock.Unlock()
+
+	delay := time.Duration(p.extender.options.visibilityTimeout) * time.Second / 2
+	if nextVisibleTime > 0 {
+		delay = time.Until(time.UnixMilli(nextVisibleTime)) / 2
+	}
+	if delay < minLeaseExtendDelay {
+		delay = minLeaseExtendDelay
+	}
+	return delay
+}
+
+func (p *LeaseExtender) reportError(err error) {
+	if p.options.errorHandler != nil {
+		p.options.errorHandler(err)
+	}
+}
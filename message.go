@@ -3,6 +3,9 @@ package ali_mns
 import (
 	"encoding/json"
 	"encoding/xml"
+	"strconv"
+
+	"github.com/gogap/errors"
 )
 
 type NotifyStrategyType string
@@ -49,11 +52,113 @@ type ErrorResponse struct {
 	HostId    string   `xml:"HostId,omitempty" json:"host_id,omitempty"`
 }
 
+type MessagePropertyType string
+
+const (
+	STRING_PROPERTY  MessagePropertyType = "STRING"
+	NUMBER_PROPERTY  MessagePropertyType = "NUMBER"
+	BOOLEAN_PROPERTY MessagePropertyType = "BOOLEAN"
+	BINARY_PROPERTY  MessagePropertyType = "BINARY"
+)
+
+type MessagePropertyValue struct {
+	XMLName xml.Name            `xml:"PropertyValue" json:"-"`
+	Name    string              `xml:"Name" json:"name"`
+	Value   string              `xml:"Value" json:"value"`
+	Type    MessagePropertyType `xml:"Type" json:"type"`
+}
+
+// MessageProperties are user defined key/value pairs sent along with the message body.
+type MessageProperties []MessagePropertyValue
+
+func (p MessageProperties) Get(name string) (value string, ok bool) {
+	for _, property := range p {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set adds a STRING property or replaces the property with the same name.
+func (p *MessageProperties) Set(name string, value string) {
+	p.SetTyped(name, value, STRING_PROPERTY)
+}
+
+func (p *MessageProperties) SetTyped(name string, value string, propertyType MessagePropertyType) {
+	for i := range *p {
+		if (*p)[i].Name == name {
+			(*p)[i].Value = value
+			(*p)[i].Type = propertyType
+			return
+		}
+	}
+	*p = append(*p, MessagePropertyValue{Name: name, Value: value, Type: propertyType})
+}
+
+type messagePropertiesXML struct {
+	Values []MessagePropertyValue `xml:"PropertyValue"`
+}
+
+func (p MessageProperties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(messagePropertiesXML{Values: p}, start)
+}
+
+func (p *MessageProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	n := messagePropertiesXML{}
+	if err := d.DecodeElement(&n, &start); err != nil {
+		return err
+	}
+	*p = append(*p, n.Values...)
+	return nil
+}
+
+func (p *MessageProperties) Delete(name string) {
+	properties := (*p)[:0]
+	for _, property := range *p {
+		if property.Name != name {
+			properties = append(properties, property)
+		}
+	}
+	*p = properties
+}
+
+func checkMessageProperties(properties MessageProperties) (err error) {
+	names := map[string]bool{}
+	for _, property := range properties {
+		reason := ""
+		switch {
+		case property.Name == "":
+			reason = "name is empty"
+		case names[property.Name]:
+			reason = "duplicated name"
+		case property.Type == NUMBER_PROPERTY:
+			if _, e := strconv.ParseFloat(property.Value, 64); e != nil {
+				reason = "value is not a number"
+			}
+		case property.Type == BOOLEAN_PROPERTY:
+			if _, e := strconv.ParseBool(property.Value); e != nil {
+				reason = "value is not a boolean"
+			}
+		case property.Type == STRING_PROPERTY || property.Type == BINARY_PROPERTY:
+		default:
+			reason = "unknown type " + string(property.Type)
+		}
+
+		if reason != "" {
+			return ERR_MNS_INVALID_MESSAGE_PROPERTY.New(errors.Params{"name": property.Name, "reason": reason})
+		}
+		names[property.Name] = true
+	}
+	return
+}
+
 type MessageSendRequest struct {
-	XMLName      xml.Name `xml:"Message" json:"-"`
-	MessageBody  string   `xml:"MessageBody" json:"message_body"`
-	DelaySeconds int64    `xml:"DelaySeconds" json:"delay_seconds"`
-	Priority     int64    `xml:"Priority" json:"priority"`
+	XMLName        xml.Name          `xml:"Message" json:"-"`
+	MessageBody    string            `xml:"MessageBody" json:"message_body"`
+	DelaySeconds   int64             `xml:"DelaySeconds" json:"delay_seconds"`
+	Priority       int64             `xml:"Priority" json:"priority"`
+	UserProperties MessageProperties `xml:"UserProperties,omitempty" json:"user_properties,omitempty"`
 }
 
 type MessagePublishRequest struct {
@@ -61,6 +166,7 @@ type MessagePublishRequest struct {
 	MessageBody       string             `xml:"MessageBody" json:"message_body"`
 	MessageTag        string             `xml:"MessageTag,omitempty" json:"message_tag,omitempty"`
 	MessageAttributes *MessageAttributes `xml:"MessageAttributes,omitempty" json:"message_attributes,omitempty"`
+	UserProperties    MessageProperties  `xml:"UserProperties,omitempty" json:"user_properties,omitempty"`
 }
 
 type MessageAttributes struct {
@@ -190,6 +296,8 @@ type MessageReceiveResponse struct {
 	FirstDequeueTime int64  `xml:"FirstDequeueTime" json:"first_dequeue_time"`
 	DequeueCount     int64  `xml:"DequeueCount" json:"dequeue_count"`
 	Priority         int64  `xml:"Priority" json:"priority"`
+
+	UserProperties MessageProperties `xml:"UserProperties,omitempty" json:"user_properties,omitempty"`
 }
 
 type BatchMessageReceiveResponse struct {
@@ -0,0 +1,186 @@
+package ali_mns
+
+import (
+	"fmt"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	"github.com/gogap/errors"
+)
+
+const (
+	messageEnvelopePrefix    = "MNS1|"
+	messageEnvelopeSeparator = "|"
+)
+
+// MessageBodyCodec transforms message bodies on the client side. EncodeBody is applied
+// before a message is sent or published, DecodeBody after a message is received or peeked.
+// DecodeBody must return bodies it does not recognise unchanged.
+type MessageBodyCodec interface {
+	EncodeBody(body string) (string, error)
+	DecodeBody(body string) (string, error)
+}
+
+// bodyReleaser is implemented by codecs which keep state outside of the message body.
+// The returned func, if not nil, is called after the message has been deleted.
+type bodyReleaser interface {
+	releaser(body string) func() error
+}
+
+// MessageBodyDecodeError is returned by the receive and peek calls when the configured
+// codecs fail to decode one or more received bodies. Messages holds every message of the
+// response, failed ones with their raw body so that they can still be deleted.
+type MessageBodyDecodeError struct {
+	Messages []MessageReceiveResponse
+	Errors   map[int]error
+}
+
+func (e *MessageBodyDecodeError) Error() string {
+	indexes := e.failedIndexes()
+	if len(indexes) == 0 {
+		return "ali_mns: decode message body failed"
+	}
+	first := indexes[0]
+	return fmt.Sprintf("ali_mns: decode body of %d message(s) failed, message id: %s, error: %v",
+		len(indexes), e.Messages[first].MessageId, e.Errors[first])
+}
+
+func (e *MessageBodyDecodeError) Unwrap() []error {
+	errs := []error{}
+	for _, index := range e.failedIndexes() {
+		errs = append(errs, e.Errors[index])
+	}
+	return errs
+}
+
+func (e *MessageBodyDecodeError) failedIndexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// messageEnvelope is the self-describing body format used by the built-in codecs:
+//
+//	MNS1|<kind>|<url encoded headers>|<payload>
+type messageEnvelope struct {
+	kind    string
+	headers neturl.Values
+	payload string
+}
+
+func (e messageEnvelope) String() string {
+	headers := ""
+	if e.headers != nil {
+		headers = e.headers.Encode()
+	}
+	return messageEnvelopePrefix + e.kind + messageEnvelopeSeparator + headers + messageEnvelopeSeparator + e.payload
+}
+
+func parseMessageEnvelope(body string) (env messageEnvelope, ok bool) {
+	if !strings.HasPrefix(body, messageEnvelopePrefix) {
+		return
+	}
+
+	pieces := strings.SplitN(body[len(messageEnvelopePrefix):], messageEnvelopeSeparator, 3)
+	if len(pieces) != 3 || pieces[0] == "" {
+		return
+	}
+
+	headers, err := neturl.ParseQuery(pieces[1])
+	if err != nil {
+		return
+	}
+
+	return messageEnvelope{kind: pieces[0], headers: headers, payload: pieces[2]}, true
+}
+
+// MessageBodyEnvelopeKind reports whether body was produced by one of the built-in codecs
+// and, if so, which one. It lets consumers without the codec detect and reject such bodies.
+func MessageBodyEnvelopeKind(body string) (kind string, ok bool) {
+	env, ok := parseMessageEnvelope(body)
+	return env.kind, ok
+}
+
+func encodeMessageBody(codecs []MessageBodyCodec, body string) (string, error) {
+	var err error
+	for i, codec := range codecs {
+		input := body
+		if body, err = codec.EncodeBody(body); err != nil {
+			return "", err
+		}
+
+		// a body kept by a claim check must still fit after the codecs that follow
+		claimCheck, ok := codec.(*ClaimCheckCodec)
+		if !ok || body != input {
+			continue
+		}
+		encoded, err := encodeMessageBody(codecs[i+1:], body)
+		if err != nil {
+			return "", err
+		}
+		if len(encoded) <= claimCheck.threshold {
+			return encoded, nil
+		}
+		if body, err = claimCheck.offload(input); err != nil {
+			return "", err
+		}
+	}
+	return body, nil
+}
+
+func decodeMessageBody(codecs []MessageBodyCodec, body string) (string, []func() error, error) {
+	var err error
+	releases := []func() error{}
+	for i := len(codecs) - 1; i >= 0; i-- {
+		if releaser, ok := codecs[i].(bodyReleaser); ok {
+			if release := releaser.releaser(body); release != nil {
+				releases = append(releases, release)
+			}
+		}
+		if body, err = codecs[i].DecodeBody(body); err != nil {
+			return "", nil, err
+		}
+	}
+	return body, releases, nil
+}
+
+// decodeReceivedMessages decodes the bodies in place. track, if not nil, is called for
+// every decoded message which holds resources to release once the message is deleted.
+func decodeReceivedMessages(codecs []MessageBodyCodec, messages []MessageReceiveResponse, track func(msg MessageReceiveResponse, releases []func() error)) error {
+	if len(codecs) == 0 {
+		return nil
+	}
+
+	var decodeErr *MessageBodyDecodeError
+	for i := range messages {
+		body, releases, err := decodeMessageBody(codecs, messages[i].MessageBody)
+		if err != nil {
+			if decodeErr == nil {
+				decodeErr = &MessageBodyDecodeError{Messages: messages, Errors: map[int]error{}}
+			}
+			decodeErr.Errors[i] = err
+			continue
+		}
+		messages[i].MessageBody = body
+		if track != nil && len(releases) > 0 {
+			track(messages[i], releases)
+		}
+	}
+
+	if decodeErr != nil {
+		return decodeErr
+	}
+	return nil
+}
+
+func newDecodeBodyError(codec string, err interface{}) error {
+	return ERR_MNS_DECODE_MESSAGE_BODY_FAILED.New(errors.Params{"codec": codec, "err": err})
+}
+
+func newEncodeBodyError(codec string, err interface{}) error {
+	return ERR_MNS_ENCODE_MESSAGE_BODY_FAILED.New(errors.Params{"codec": codec, "err": err})
+}
@@ -0,0 +1,71 @@
+package ali_mns
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// MessageBodyEncoding controls how message bodies are represented on the wire. The
+// Java and Python MNS SDKs base64 encode bodies by default, use BASE64_ENCODING to
+// exchange messages with them. RAW_ENCODING is the default.
+type MessageBodyEncoding string
+
+const (
+	RAW_ENCODING    MessageBodyEncoding = "RAW"
+	BASE64_ENCODING MessageBodyEncoding = "BASE64"
+	// AUTO_ENCODING sends bodies unchanged and decodes received bodies which look base64
+	// encoded. It is lossy: a raw body which happens to be valid base64 of UTF-8 text, such
+	// as "Zm9v", is decoded too. Only use it while migrating a queue whose producers mix
+	// both encodings, and prefer RAW_ENCODING or BASE64_ENCODING otherwise.
+	AUTO_ENCODING MessageBodyEncoding = "AUTO"
+)
+
+type base64BodyCodec struct {
+	encoding MessageBodyEncoding
+}
+
+func (p *base64BodyCodec) EncodeBody(body string) (string, error) {
+	return EncodeMessageBody(body, p.encoding), nil
+}
+
+func (p *base64BodyCodec) DecodeBody(body string) (string, error) {
+	return DecodeMessageBody(body, p.encoding)
+}
+
+// DetectMessageBodyEncoding guesses the encoding of a received body. A body is reported
+// as BASE64_ENCODING when it is valid standard base64 and decodes to valid UTF-8 text.
+// The guess can not tell such a body from raw text which only looks encoded.
+func DetectMessageBodyEncoding(body string) MessageBodyEncoding {
+	if body == "" || len(body)%4 != 0 {
+		return RAW_ENCODING
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil || !utf8.Valid(decoded) {
+		return RAW_ENCODING
+	}
+	return BASE64_ENCODING
+}
+
+func EncodeMessageBody(body string, encoding MessageBodyEncoding) string {
+	if encoding == BASE64_ENCODING {
+		return base64.StdEncoding.EncodeToString([]byte(body))
+	}
+	return body
+}
+
+func DecodeMessageBody(body string, encoding MessageBodyEncoding) (string, error) {
+	if encoding == AUTO_ENCODING {
+		encoding = DetectMessageBodyEncoding(body)
+	}
+
+	if encoding != BASE64_ENCODING {
+		return body, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", newDecodeBodyError(string(BASE64_ENCODING), err)
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,142 @@
+package ali_mns
+
+import (
+	"time"
+)
+
+const (
+	DefaultBatchRetryBackoff time.Duration = 200 * time.Millisecond
+	DefaultBatchParallelism  int           = 4
+)
+
+type MNSOptions struct {
+	qpsLimit          int32
+	rateLimiter       *RateLimiter
+	adaptiveLimiter   *AdaptiveRateLimiter
+	codecs            []MessageBodyCodec
+	encoding          MessageBodyEncoding
+	batchRetries      int
+	batchRetryBackoff time.Duration
+	batchParallelism  int
+	asyncSender       *AsyncSender
+}
+
+// MNSOption configures the client side behaviour of a queue or topic created by
+// NewMNSQueueWithOptions or NewMNSTopicWithOptions.
+type MNSOption func(*MNSOptions)
+
+func WithQPSLimit(qps int32) MNSOption {
+	return func(o *MNSOptions) {
+		if qps > 0 {
+			o.qpsLimit = qps
+		}
+	}
+}
+
+// WithRateLimiter makes requests wait for limiter instead of a limiter of their own, so
+// that several queues and topics share one limit. It takes precedence over WithQPSLimit.
+func WithRateLimiter(limiter *RateLimiter) MNSOption {
+	return func(o *MNSOptions) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithAdaptiveRateLimiter makes requests wait for limiter and adjusts its rate by the
+// responses, see AdaptiveRateLimiter. The current rate is reported by the RateLimiter of the
+// QPSMonitor. It takes precedence over WithRateLimiter and WithQPSLimit.
+func WithAdaptiveRateLimiter(limiter *AdaptiveRateLimiter) MNSOption {
+	return func(o *MNSOptions) {
+		o.adaptiveLimiter = limiter
+	}
+}
+
+// WithMessageBodyCodec appends a codec to the body codec chain. Codecs encode outgoing
+// bodies in the order they are added and decode received bodies in reverse order. Combine
+// them as compression, claim check, then encryption, see ClaimCheckCodec.
+func WithMessageBodyCodec(codec MessageBodyCodec) MNSOption {
+	return func(o *MNSOptions) {
+		if codec != nil {
+			o.codecs = append(o.codecs, codec)
+		}
+	}
+}
+
+// WithMessageBodyEncoding sets the wire encoding of message bodies, RAW_ENCODING by
+// default. The encoding is applied after every body codec when sending and before them
+// when receiving. AUTO_ENCODING may decode raw bodies by mistake, see its doc.
+func WithMessageBodyEncoding(encoding MessageBodyEncoding) MNSOption {
+	return func(o *MNSOptions) {
+		o.encoding = encoding
+	}
+}
+
+// WithBatchRetry makes BatchSendMessage and BatchDeleteMessage resend the entries which
+// failed with a retryable code, up to retries more times. The wait before each round grows
+// linearly with backoff, DefaultBatchRetryBackoff is used when backoff is not positive.
+func WithBatchRetry(retries int, backoff time.Duration) MNSOption {
+	return func(o *MNSOptions) {
+		if retries < 0 {
+			retries = 0
+		}
+		if backoff <= 0 {
+			backoff = DefaultBatchRetryBackoff
+		}
+		o.batchRetries = retries
+		o.batchRetryBackoff = backoff
+	}
+}
+
+// WithBatchParallelism sets how many requests an oversized BatchSendMessage or
+// BatchDeleteMessage call runs at the same time, every request still passes the QPS limit.
+func WithBatchParallelism(parallelism int) MNSOption {
+	return func(o *MNSOptions) {
+		if parallelism > 0 {
+			o.batchParallelism = parallelism
+		}
+	}
+}
+
+// WithAsyncSender makes SendMessageAsync of a queue and PublishMessageAsync of a topic run
+// on sender, which is usually shared by all queues and topics of a client.
+func WithAsyncSender(sender *AsyncSender) MNSOption {
+	return func(o *MNSOptions) {
+		o.asyncSender = sender
+	}
+}
+
+func newMNSOptions(defaultQPSLimit int32, options ...MNSOption) *MNSOptions {
+	o := &MNSOptions{qpsLimit: defaultQPSLimit, encoding: RAW_ENCODING, batchParallelism: DefaultBatchParallelism}
+	for _, option := range options {
+		if option != nil {
+			option(o)
+		}
+	}
+	return o
+}
+
+func (o *MNSOptions) qpsMonitor() *QPSMonitor {
+	if o.adaptiveLimiter != nil {
+		return NewQPSMonitorWithLimiter(5, o.adaptiveLimiter.RateLimiter())
+	}
+	if o.rateLimiter != nil {
+		return NewQPSMonitorWithLimiter(5, o.rateLimiter)
+	}
+	return NewQPSMonitor(5, o.qpsLimit)
+}
+
+// mnsClient returns client reporting its responses to the adaptive limiter, the limiter
+// itself is waited for by the QPSMonitor.
+func (o *MNSOptions) mnsClient(client MNSClient) MNSClient {
+	if o.adaptiveLimiter != nil {
+		return &adaptiveClient{MNSClient: client, limiter: o.adaptiveLimiter}
+	}
+	return client
+}
+
+func (o *MNSOptions) bodyCodecs() []MessageBodyCodec {
+	codecs := append([]MessageBodyCodec{}, o.codecs...)
+	if o.encoding != "" && o.encoding != RAW_ENCODING {
+		codecs = append(codecs, &base64BodyCodec{encoding: o.encoding})
+	}
+	return codecs
+}
@@ -0,0 +1,131 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+)
+
+// PageError reports the page at which a paginated list operation failed. Page counts from
+// 1 and Marker is the marker the failing request was sent with.
+type PageError struct {
+	Page   int
+	Marker string
+	Err    error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("ali_mns: list page %d (marker %q) failed, %v", e.Page, e.Marker, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// Paginator walks all pages of a list operation by following NextMarker. It is not safe
+// for concurrent use.
+type Paginator[T any] struct {
+	fetch  func(marker string) (items []T, nextMarker string, err error)
+	marker string
+	page   int
+	done   bool
+}
+
+func newPaginator[T any](fetch func(marker string) ([]T, string, error)) *Paginator[T] {
+	return &Paginator[T]{fetch: fetch}
+}
+
+// HasMorePages reports whether NextPage may return more items.
+func (p *Paginator[T]) HasMorePages() bool {
+	return !p.done
+}
+
+// NextPage fetches the next page. After an error the same page is requested again by the
+// next call.
+func (p *Paginator[T]) NextPage(ctx context.Context) (items []T, err error) {
+	if p.done {
+		return nil, nil
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	items, nextMarker, err := p.fetch(p.marker)
+	if err != nil {
+		return nil, &PageError{Page: p.page + 1, Marker: p.marker, Err: err}
+	}
+
+	p.page++
+	p.marker = nextMarker
+	p.done = nextMarker == ""
+	return items, nil
+}
+
+// ForEach calls fn for every item of the remaining pages until fn returns an error, a page
+// fails or ctx is done.
+func (p *Paginator[T]) ForEach(ctx context.Context, fn func(item T) error) error {
+	for p.HasMorePages() {
+		items, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err = fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Collect returns the items of all remaining pages.
+func (p *Paginator[T]) Collect(ctx context.Context) (items []T, err error) {
+	err = p.ForEach(ctx, func(item T) error {
+		items = append(items, item)
+		return nil
+	})
+	return
+}
+
+// NewQueuePaginator lists the queues whose name starts with prefix, pageSize queues per
+// request (1~1000, 0 for the service default).
+func NewQueuePaginator(manager AliQueueManager, prefix string, pageSize int32) *Paginator[Queue] {
+	return newPaginator(func(marker string) ([]Queue, string, error) {
+		resp, err := manager.ListQueue(marker, pageSize, prefix)
+		return resp.Queues, resp.NextMarker, err
+	})
+}
+
+func NewQueueDetailPaginator(manager AliQueueManager, prefix string, pageSize int32) *Paginator[QueueAttribute] {
+	return newPaginator(func(marker string) ([]QueueAttribute, string, error) {
+		resp, err := manager.ListQueueDetail(marker, pageSize, prefix)
+		return resp.Attrs, resp.NextMarker, err
+	})
+}
+
+func NewTopicPaginator(manager AliTopicManager, prefix string, pageSize int32) *Paginator[Topic] {
+	return newPaginator(func(marker string) ([]Topic, string, error) {
+		resp, err := manager.ListTopic(marker, pageSize, prefix)
+		return resp.Topics, resp.NextMarker, err
+	})
+}
+
+func NewTopicDetailPaginator(manager AliTopicManager, prefix string, pageSize int32) *Paginator[TopicAttribute] {
+	return newPaginator(func(marker string) ([]TopicAttribute, string, error) {
+		resp, err := manager.ListTopicDetail(marker, pageSize, prefix)
+		return resp.Attrs, resp.NextMarker, err
+	})
+}
+
+func NewSubscriptionPaginator(topic AliMNSTopic, prefix string, pageSize int32) *Paginator[Subscription] {
+	return newPaginator(func(marker string) ([]Subscription, string, error) {
+		resp, err := topic.ListSubscriptionByTopic(marker, pageSize, prefix)
+		return resp.Subscriptions, resp.NextMarker, err
+	})
+}
+
+func NewSubscriptionDetailPaginator(topic AliMNSTopic, prefix string, pageSize int32) *Paginator[SubscriptionAttribute] {
+	return newPaginator(func(marker string) ([]SubscriptionAttribute, string, error) {
+		resp, err := topic.ListSubscriptionDetailByTopic(marker, pageSize, prefix)
+		return resp.Attrs, resp.NextMarker, err
+	})
+}
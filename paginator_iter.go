@@ -0,0 +1,52 @@
+//go:build go1.23
+
+package ali_mns
+
+import (
+	"context"
+	"iter"
+)
+
+// All yields every item of the remaining pages. A failing page or a done ctx is yielded
+// once as the error of a zero item, then the sequence ends.
+func (p *Paginator[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for p.HasMorePages() {
+			items, err := p.NextPage(ctx)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func AllQueues(ctx context.Context, manager AliQueueManager, prefix string, pageSize int32) iter.Seq2[Queue, error] {
+	return NewQueuePaginator(manager, prefix, pageSize).All(ctx)
+}
+
+func AllQueueDetails(ctx context.Context, manager AliQueueManager, prefix string, pageSize int32) iter.Seq2[QueueAttribute, error] {
+	return NewQueueDetailPaginator(manager, prefix, pageSize).All(ctx)
+}
+
+func AllTopics(ctx context.Context, manager AliTopicManager, prefix string, pageSize int32) iter.Seq2[Topic, error] {
+	return NewTopicPaginator(manager, prefix, pageSize).All(ctx)
+}
+
+func AllTopicDetails(ctx context.Context, manager AliTopicManager, prefix string, pageSize int32) iter.Seq2[TopicAttribute, error] {
+	return NewTopicDetailPaginator(manager, prefix, pageSize).All(ctx)
+}
+
+func AllSubscriptions(ctx context.Context, topic AliMNSTopic, prefix string, pageSize int32) iter.Seq2[Subscription, error] {
+	return NewSubscriptionPaginator(topic, prefix, pageSize).All(ctx)
+}
+
+func AllSubscriptionDetails(ctx context.Context, topic AliMNSTopic, prefix string, pageSize int32) iter.Seq2[SubscriptionAttribute, error] {
+	return NewSubscriptionDetailPaginator(topic, prefix, pageSize).All(ctx)
+}
@@ -0,0 +1,232 @@
+package ali_mns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultProducerLinger        time.Duration = 100 * time.Millisecond
+	DefaultProducerMaxBatchBytes int           = MaxBatchBodyBytes
+)
+
+// SendFuture is the pending result of an asynchronously sent message.
+type SendFuture struct {
+	done chan struct{}
+	resp MessageSendResponse
+	err  error
+}
+
+func newSendFuture() *SendFuture {
+	return &SendFuture{done: make(chan struct{})}
+}
+
+func (p *SendFuture) resolve(resp MessageSendResponse, err error) {
+	p.resp, p.err = resp, err
+	close(p.done)
+}
+
+// Done is closed once the result is available.
+func (p *SendFuture) Done() <-chan struct{} {
+	return p.done
+}
+
+// Result blocks until the message is sent or failed.
+func (p *SendFuture) Result() (MessageSendResponse, error) {
+	<-p.done
+	return p.resp, p.err
+}
+
+// Wait is like Result but gives up when ctx is done, the message may still be sent later.
+func (p *SendFuture) Wait(ctx context.Context) (MessageSendResponse, error) {
+	select {
+	case <-p.done:
+		return p.resp, p.err
+	case <-ctx.Done():
+		return MessageSendResponse{}, ctx.Err()
+	}
+}
+
+type ProducerOptions struct {
+	batchSize     int
+	maxBatchBytes int
+	linger        time.Duration
+}
+
+type ProducerOption func(*ProducerOptions)
+
+// WithProducerBatchSize sets how many messages are sent in one batch at most, 1~16.
+func WithProducerBatchSize(batchSize int) ProducerOption {
+	return func(o *ProducerOptions) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithProducerMaxBatchBytes sets the maximum total body size of a batch. Bodies are
+// measured before the body codecs of the queue are applied, so lower it when a codec
+// enlarges bodies, e.g. base64 encoding.
+func WithProducerMaxBatchBytes(maxBatchBytes int) ProducerOption {
+	return func(o *ProducerOptions) {
+		o.maxBatchBytes = maxBatchBytes
+	}
+}
+
+// WithProducerLinger sets how long a message is buffered at most before it is sent.
+func WithProducerLinger(linger time.Duration) ProducerOption {
+	return func(o *ProducerOptions) {
+		o.linger = linger
+	}
+}
+
+type pendingSend struct {
+	message MessageSendRequest
+	future  *SendFuture
+}
+
+// Producer buffers messages and sends them with BatchSendMessage, either when a batch is
+// full or when the oldest message has lingered long enough.
+type Producer struct {
+	queue   AliMNSQueue
+	options ProducerOptions
+
+	lock         sync.Mutex
+	pending      []pendingSend
+	pendingBytes int
+	timer        *time.Timer
+	closed       bool
+	flushes      sync.WaitGroup
+}
+
+func NewProducer(queue AliMNSQueue, options ...ProducerOption) (*Producer, error) {
+	if queue == nil {
+		return nil, fmt.Errorf("ali_mns: producer queue could not be nil")
+	}
+
+	o := ProducerOptions{
+		batchSize:     int(DefaultNumOfMessages),
+		maxBatchBytes: DefaultProducerMaxBatchBytes,
+		linger:        DefaultProducerLinger,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&o)
+		}
+	}
+
+	if o.batchSize <= 0 || o.batchSize > int(DefaultNumOfMessages) {
+		return nil, fmt.Errorf("ali_mns: producer batch size is not in range of (1~%d)", DefaultNumOfMessages)
+	}
+	if o.maxBatchBytes <= 0 {
+		return nil, fmt.Errorf("ali_mns: producer max batch bytes must be positive")
+	}
+	if o.linger <= 0 {
+		return nil, fmt.Errorf("ali_mns: producer linger must be positive")
+	}
+
+	return &Producer{queue: queue, options: o}, nil
+}
+
+// Send buffers message and returns a future resolved with its MessageId, or with a
+// *BatchEntryError when the service rejected it. A message with invalid user properties
+// fails at once, so that it does not fail the batch it would have joined.
+func (p *Producer) Send(message MessageSendRequest) *SendFuture {
+	future := newSendFuture()
+	if err := checkMessageProperties(message.UserProperties); err != nil {
+		future.resolve(MessageSendResponse{}, err)
+		return future
+	}
+	size := len(message.MessageBody)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		future.resolve(MessageSendResponse{}, fmt.Errorf("ali_mns: producer is closed"))
+		return future
+	}
+
+	if len(p.pending) > 0 && p.pendingBytes+size > p.options.maxBatchBytes {
+		p.flushLocked()
+	}
+
+	p.pending = append(p.pending, pendingSend{message: message, future: future})
+	p.pendingBytes += size
+
+	if len(p.pending) >= p.options.batchSize || p.pendingBytes >= p.options.maxBatchBytes {
+		p.flushLocked()
+	} else if p.timer == nil {
+		p.timer = time.AfterFunc(p.options.linger, p.Flush)
+	}
+	return future
+}
+
+// Flush sends the buffered messages without waiting for the results.
+func (p *Producer) Flush() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.flushLocked()
+}
+
+// Close sends the buffered messages and waits until all futures are resolved. Later calls
+// to Send fail.
+func (p *Producer) Close() {
+	p.lock.Lock()
+	p.closed = true
+	p.flushLocked()
+	p.lock.Unlock()
+
+	p.flushes.Wait()
+}
+
+func (p *Producer) flushLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if len(p.pending) == 0 {
+		return
+	}
+
+	batch := p.pending
+	p.pending = nil
+	p.pendingBytes = 0
+
+	p.flushes.Add(1)
+	go func() {
+		defer p.flushes.Done()
+		p.sendBatch(batch)
+	}()
+}
+
+func (p *Producer) sendBatch(batch []pendingSend) {
+	messages := make([]MessageSendRequest, 0, len(batch))
+	for _, entry := range batch {
+		messages = append(messages, entry.message)
+	}
+
+	resp, err := p.queue.BatchSendMessage(messages...)
+	if len(resp.Messages) != len(batch) {
+		if err == nil {
+			err = fmt.Errorf("ali_mns: batch send returned %d entries for %d messages", len(resp.Messages), len(batch))
+		}
+		for _, entry := range batch {
+			entry.future.resolve(MessageSendResponse{}, err)
+		}
+		return
+	}
+
+	for i, entry := range batch {
+		result := resp.Messages[i]
+		if result.ErrorCode != "" {
+			entry.future.resolve(MessageSendResponse{}, &BatchEntryError{Index: i, ErrorCode: result.ErrorCode, ErrorMessage: result.ErrorMessage})
+			continue
+		}
+		entry.future.resolve(MessageSendResponse{
+			MessageResponse: MessageResponse{BaseResponse: resp.BaseResponse},
+			MessageId:       result.MessageId,
+			MessageBodyMD5:  result.MessageBodyMD5,
+		}, nil)
+	}
+}
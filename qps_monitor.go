@@ -1,15 +1,19 @@
 package ali_mns
 
 import (
+	"context"
 	"sync/atomic"
 	"time"
 )
 
+// QPSMonitor counts the requests of a queue or topic and holds them back by its
+// RateLimiter. It is kept for compatibility, new code shares a RateLimiter instead.
 type QPSMonitor struct {
 	qpsLimit     int32
 	latestIndex  int32
 	delaySecond  int32
 	totalQueries []int32
+	limiter      *RateLimiter
 }
 
 func (p *QPSMonitor) Pulse() {
@@ -20,41 +24,62 @@ func (p *QPSMonitor) Pulse() {
 func (p *QPSMonitor) Update() int32 {
 	index := int32(time.Now().Second()) % p.delaySecond
 
-	if p.latestIndex != index {
-		atomic.StoreInt32(&p.latestIndex, index)
-		atomic.StoreInt32(&p.totalQueries[p.latestIndex], 0)
+	latest := atomic.LoadInt32(&p.latestIndex)
+	if latest != index && atomic.CompareAndSwapInt32(&p.latestIndex, latest, index) {
+		atomic.StoreInt32(&p.totalQueries[index], 0)
 	}
 	return index
 }
 
 func (p *QPSMonitor) QPS() int32 {
 	var totalCount int32 = 0
-	for i, queryCount := range p.totalQueries {
-		if int32(i) != p.latestIndex {
-			totalCount += queryCount
+	latest := atomic.LoadInt32(&p.latestIndex)
+	for i := range p.totalQueries {
+		if int32(i) != latest {
+			totalCount += atomic.LoadInt32(&p.totalQueries[i])
 		}
 	}
 	return totalCount / (p.delaySecond - 1)
 }
 
+// RateLimiter returns the limiter requests wait for, nil when there is no limit.
+func (p *QPSMonitor) RateLimiter() *RateLimiter {
+	return p.limiter
+}
+
 func (p *QPSMonitor) checkQPS() {
 	p.Pulse()
-	if p.qpsLimit > 0 {
-		for p.QPS() > p.qpsLimit {
-			time.Sleep(time.Millisecond * 10)
-            		p.Update()
-		}
+	if p.limiter != nil {
+		p.limiter.Wait(context.Background())
 	}
 }
 
 func NewQPSMonitor(delaySecond int32, qpsLimit int32) *QPSMonitor {
+	var limiter *RateLimiter
+	if qpsLimit > 0 {
+		limiter = NewRateLimiter(float64(qpsLimit), int(qpsLimit))
+	}
+	monitor := newQPSMonitor(delaySecond, limiter)
+	monitor.qpsLimit = qpsLimit
+	return monitor
+}
+
+// NewQPSMonitorWithLimiter creates a monitor whose requests wait for a shared limiter.
+func NewQPSMonitorWithLimiter(delaySecond int32, limiter *RateLimiter) *QPSMonitor {
+	monitor := newQPSMonitor(delaySecond, limiter)
+	if limiter != nil {
+		monitor.qpsLimit = int32(limiter.Rate())
+	}
+	return monitor
+}
+
+func newQPSMonitor(delaySecond int32, limiter *RateLimiter) *QPSMonitor {
 	if delaySecond < 5 {
 		delaySecond = 5
 	}
-	monitor := QPSMonitor{
-		qpsLimit:     qpsLimit,
+	return &QPSMonitor{
 		delaySecond:  delaySecond,
 		totalQueries: make([]int32, delaySecond),
+		limiter:      limiter,
 	}
-	return &monitor
 }
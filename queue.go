@@ -3,6 +3,8 @@ package ali_mns
 import (
 	"fmt"
 	"net/url"
+	"sync"
+	"time"
 )
 
 var (
@@ -27,26 +29,54 @@ type MNSQueue struct {
 	name    string
 	client  MNSClient
 	decoder MNSDecoder
+	codecs  []MessageBodyCodec
 
-	qpsMonitor *QPSMonitor
+	batchRetries      int
+	batchRetryBackoff time.Duration
+	batchParallelism  int
+
+	qpsMonitor  *QPSMonitor
+	asyncSender *AsyncSender
+
+	releaseLocker sync.Mutex
+	releases      map[string]messageReleases
+	lastPrune     time.Time
+}
+
+// messageReleases holds the cleanup funcs of a received message until it is deleted or
+// its receipt handle expires.
+type messageReleases struct {
+	expire   time.Time
+	releases []func() error
 }
 
 func NewMNSQueue(name string, client MNSClient, qps ...int32) (AliMNSQueue, error) {
-    if name == "" {
-        return nil, fmt.Errorf("ali_mns: queue name could not be empty")
-    }
+	options := []MNSOption{}
+	if qps != nil && len(qps) == 1 {
+		options = append(options, WithQPSLimit(qps[0]))
+	}
+	return NewMNSQueueWithOptions(name, client, options...)
+}
+
+func NewMNSQueueWithOptions(name string, client MNSClient, options ...MNSOption) (AliMNSQueue, error) {
+	if name == "" {
+		return nil, fmt.Errorf("ali_mns: queue name could not be empty")
+	}
 
-    queue := new(MNSQueue)
-    queue.client = client
-    queue.name = name
-    queue.decoder = NewAliMNSDecoder()
+	o := newMNSOptions(DefaultQueueQPSLimit, options...)
 
-    qpsLimit := DefaultQueueQPSLimit
-    if qps != nil && len(qps) == 1 && qps[0] > 0 {
-        qpsLimit = qps[0]
-    }
-    queue.qpsMonitor = NewQPSMonitor(5, qpsLimit)
-    return queue, nil
+	queue := new(MNSQueue)
+	queue.client = o.mnsClient(client)
+	queue.name = name
+	queue.decoder = NewAliMNSDecoder()
+	queue.codecs = o.bodyCodecs()
+	queue.batchRetries = o.batchRetries
+	queue.batchRetryBackoff = o.batchRetryBackoff
+	queue.batchParallelism = o.batchParallelism
+	queue.releases = map[string]messageReleases{}
+	queue.qpsMonitor = o.qpsMonitor()
+	queue.asyncSender = o.asyncSender
+	return queue, nil
 }
 
 func (p *MNSQueue) QPSMonitor() *QPSMonitor {
@@ -58,6 +88,13 @@ func (p *MNSQueue) Name() string {
 }
 
 func (p *MNSQueue) SendMessage(message MessageSendRequest) (resp MessageSendResponse, err error) {
+	if err = checkMessageProperties(message.UserProperties); err != nil {
+		return
+	}
+	if message.MessageBody, err = encodeMessageBody(p.codecs, message.MessageBody); err != nil {
+		return
+	}
+
 	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, POST, nil, message, fmt.Sprintf("queues/%s/%s", p.name, "messages"), &resp)
 	return
@@ -70,42 +107,106 @@ func (p *MNSQueue) BatchSendMessage(messages ...MessageSendRequest) (resp BatchM
 
 	batchRequest := BatchMessageSendRequest{}
 	for _, message := range messages {
+		if err = checkMessageProperties(message.UserProperties); err != nil {
+			return
+		}
+		if message.MessageBody, err = encodeMessageBody(p.codecs, message.MessageBody); err != nil {
+			return
+		}
 		batchRequest.Messages = append(batchRequest.Messages, message)
 	}
 
-	p.qpsMonitor.checkQPS()
-	_, err = send(p.client, NewBatchOpDecoder(&resp), POST, nil, batchRequest, fmt.Sprintf("queues/%s/%s", p.name, "messages"), &resp)
+	if resp, err = p.batchSend(batchRequest); err != nil {
+		return
+	}
+
+	failed := batchSendFailures(resp)
+	for attempt := 0; attempt < p.batchRetries; attempt++ {
+		indexes := retryableIndexes(failed)
+		if len(indexes) == 0 {
+			break
+		}
+		time.Sleep(batchRetryDelay(p.batchRetryBackoff, attempt))
+
+		retryRequest := BatchMessageSendRequest{}
+		for _, i := range indexes {
+			retryRequest.Messages = append(retryRequest.Messages, batchRequest.Messages[i])
+		}
+		retryResp, retryErr := p.batchSend(retryRequest)
+		if retryErr != nil || len(retryResp.Messages) != len(indexes) {
+			break
+		}
+		for j, i := range indexes {
+			resp.Messages[i] = retryResp.Messages[j]
+		}
+		failed = batchSendFailures(resp)
+	}
+
+	err = newBatchError(len(batchRequest.Messages), failed)
 	return
 }
 
-func (p *MNSQueue) ReceiveMessage(respChan chan MessageReceiveResponse, errChan chan error, waitseconds ...int64) {
-	resource := fmt.Sprintf("queues/%s/%s", p.name, "messages")
-	if waitseconds != nil {
-		for _, waitsecond := range waitseconds {
-			if waitsecond <= 0 {
-				continue
+// batchSend sends the messages in chunks that the service accepts and merges the entries
+// in input order. Entries of a chunk whose request failed get BatchChunkFailedErrorCode,
+// the error is only returned when no chunk got through.
+func (p *MNSQueue) batchSend(batchRequest BatchMessageSendRequest) (resp BatchMessageSendResponse, err error) {
+	chunks := splitBatchMessages(batchRequest.Messages)
+	if len(chunks) == 1 {
+		return p.batchSendOnce(batchRequest)
+	}
+
+	results := make([]BatchMessageSendResponse, len(chunks))
+	errs := make([]error, len(chunks))
+	runBatchChunks(len(chunks), p.batchParallelism, func(i int) {
+		results[i], errs[i] = p.batchSendOnce(BatchMessageSendRequest{Messages: batchRequest.Messages[chunks[i].start:chunks[i].end]})
+	})
+
+	failedChunks := 0
+	for i, chunk := range chunks {
+		if errs[i] == nil && len(results[i].Messages) != chunk.end-chunk.start {
+			errs[i] = fmt.Errorf("ali_mns: batch send returned %d entries for %d messages", len(results[i].Messages), chunk.end-chunk.start)
+		}
+		if errs[i] != nil {
+			failedChunks++
+			if err == nil {
+				err = errs[i]
 			}
-			resource = fmt.Sprintf("queues/%s/%s?waitseconds=%d", p.name, "messages", waitsecond)
-			p.qpsMonitor.checkQPS()
-			resp := MessageReceiveResponse{}
-			_, err := send(p.client, p.decoder, GET, nil, nil, resource, &resp)
-			if err != nil {
-				// if no
-				errChan <- err
-			} else {
-				respChan <- resp
-				// return if success, may be too much msg accumulated
-				return
+			for j := chunk.start; j < chunk.end; j++ {
+				resp.Messages = append(resp.Messages, BatchMessageSendEntry{ErrorCode: BatchChunkFailedErrorCode, ErrorMessage: errs[i].Error()})
 			}
+			continue
 		}
-	} else {
-		p.qpsMonitor.checkQPS()
-		resp := MessageReceiveResponse{}
-		_, err := send(p.client, p.decoder, GET, nil, nil, resource, &resp)
-		if err != nil {
-			errChan <- err
-		} else {
+		resp.BaseResponse = results[i].BaseResponse
+		resp.Messages = append(resp.Messages, results[i].Messages...)
+	}
+
+	if failedChunks == len(chunks) {
+		return BatchMessageSendResponse{}, err
+	}
+	return resp, nil
+}
+
+// batchSendOnce sends one batch request, the error is nil when only some entries failed.
+func (p *MNSQueue) batchSendOnce(batchRequest BatchMessageSendRequest) (resp BatchMessageSendResponse, err error) {
+	p.qpsMonitor.checkQPS()
+	_, err = send(p.client, NewBatchOpDecoder(&resp), POST, nil, batchRequest, fmt.Sprintf("queues/%s/%s", p.name, "messages"), &resp)
+	if ERR_MNS_BATCH_OP_FAIL.IsEqual(err) {
+		err = nil
+	}
+	return
+}
+
+func (p *MNSQueue) ReceiveMessage(respChan chan MessageReceiveResponse, errChan chan error, waitseconds ...int64) {
+	for _, waitsecond := range receiveAttempts(waitseconds) {
+		resp, err := p.receiveOne(waitsecond, false)
+		if err == nil {
 			respChan <- resp
+			// return if success, may be too much msg accumulated
+			return
+		}
+		errChan <- err
+		if isMessageBodyDecodeError(err) {
+			return
 		}
 	}
 	// if no message after waitsecond loop or after once try if no waitsecond offered
@@ -117,40 +218,22 @@ func (p *MNSQueue) BatchReceiveMessage(respChan chan BatchMessageReceiveResponse
 		numOfMessages = DefaultNumOfMessages
 	}
 
-	resource := fmt.Sprintf("queues/%s/%s?numOfMessages=%d", p.name, "messages", numOfMessages)
-	if waitseconds != nil {
-		for _, waitsecond := range waitseconds {
-			if waitsecond <= 0 {
-				continue
-			}
-			resource = fmt.Sprintf("queues/%s/%s?numOfMessages=%d&waitseconds=%d", p.name, "messages", numOfMessages, waitsecond)
-			p.qpsMonitor.checkQPS()
-			resp := BatchMessageReceiveResponse{}
-			_, err := send(p.client, p.decoder, GET, nil, nil, resource, &resp)
-			if err != nil {
-				errChan <- err
-			} else {
-				respChan <- resp
-				return
-			}
-		}
-	} else {
-		p.qpsMonitor.checkQPS()
-		resp := BatchMessageReceiveResponse{}
-		_, err := send(p.client, p.decoder, GET, nil, nil, resource, &resp)
-		if err != nil {
-			errChan <- err
-		} else {
+	for _, waitsecond := range receiveAttempts(waitseconds) {
+		resp, err := p.receiveBatch(numOfMessages, waitsecond, false)
+		if err == nil {
 			respChan <- resp
+			return
+		}
+		errChan <- err
+		if isMessageBodyDecodeError(err) {
+			return
 		}
 	}
 	return
 }
 
 func (p *MNSQueue) PeekMessage(respChan chan MessageReceiveResponse, errChan chan error) {
-	p.qpsMonitor.checkQPS()
-	resp := MessageReceiveResponse{}
-	_, err := send(p.client, p.decoder, GET, nil, nil, fmt.Sprintf("queues/%s/%s?peekonly=true", p.name, "messages"), &resp)
+	resp, err := p.receiveOne(0, true)
 	if err != nil {
 		errChan <- err
 	} else {
@@ -164,9 +247,7 @@ func (p *MNSQueue) BatchPeekMessage(respChan chan BatchMessageReceiveResponse, e
 		numOfMessages = DefaultNumOfMessages
 	}
 
-	p.qpsMonitor.checkQPS()
-	resp := BatchMessageReceiveResponse{}
-	_, err := send(p.client, p.decoder, GET, nil, nil, fmt.Sprintf("queues/%s/%s?numOfMessages=%d&peekonly=true", p.name, "messages", numOfMessages), &resp)
+	resp, err := p.receiveBatch(numOfMessages, 0, true)
 	if err != nil {
 		errChan <- err
 	} else {
@@ -178,6 +259,9 @@ func (p *MNSQueue) BatchPeekMessage(respChan chan BatchMessageReceiveResponse, e
 func (p *MNSQueue) DeleteMessage(receiptHandle string) (err error) {
 	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, DELETE, nil, nil, fmt.Sprintf("queues/%s/%s?ReceiptHandle=%s", p.name, "messages", url.QueryEscape(receiptHandle)), nil)
+	if err == nil {
+		p.release(receiptHandle)
+	}
 	return
 }
 
@@ -186,6 +270,88 @@ func (p *MNSQueue) BatchDeleteMessage(receiptHandles ...string) (resp BatchMessa
 		return
 	}
 
+	if resp, err = p.batchDelete(receiptHandles); err != nil {
+		return
+	}
+
+	failed := batchDeleteFailures(receiptHandles, resp)
+	for attempt := 0; attempt < p.batchRetries; attempt++ {
+		indexes := retryableIndexes(failed)
+		if len(indexes) == 0 {
+			break
+		}
+		time.Sleep(batchRetryDelay(p.batchRetryBackoff, attempt))
+
+		retryHandles := []string{}
+		for _, i := range indexes {
+			retryHandles = append(retryHandles, receiptHandles[i])
+		}
+		retryResp, retryErr := p.batchDelete(retryHandles)
+		if retryErr != nil {
+			break
+		}
+
+		retried := map[string]bool{}
+		for _, handle := range retryHandles {
+			retried[handle] = true
+		}
+		remaining := []MessageDeleteFailEntry{}
+		for _, entry := range resp.FailedMessages {
+			if !retried[entry.ReceiptHandle] {
+				remaining = append(remaining, entry)
+			}
+		}
+		resp.FailedMessages = append(remaining, retryResp.FailedMessages...)
+		failed = batchDeleteFailures(receiptHandles, resp)
+	}
+
+	err = newBatchError(len(receiptHandles), failed)
+	return
+}
+
+// batchDelete deletes the receipt handles in chunks that the service accepts. Handles of
+// a chunk whose request failed are reported with BatchChunkFailedErrorCode, the error is
+// only returned when no chunk got through.
+func (p *MNSQueue) batchDelete(receiptHandles []string) (resp BatchMessageDeleteErrorResponse, err error) {
+	chunks := splitBatchHandles(receiptHandles)
+	if len(chunks) == 1 {
+		return p.batchDeleteOnce(receiptHandles)
+	}
+
+	results := make([]BatchMessageDeleteErrorResponse, len(chunks))
+	errs := make([]error, len(chunks))
+	runBatchChunks(len(chunks), p.batchParallelism, func(i int) {
+		results[i], errs[i] = p.batchDeleteOnce(receiptHandles[chunks[i].start:chunks[i].end])
+	})
+
+	failedChunks := 0
+	for i, chunk := range chunks {
+		if errs[i] != nil {
+			failedChunks++
+			if err == nil {
+				err = errs[i]
+			}
+			for _, handle := range receiptHandles[chunk.start:chunk.end] {
+				resp.FailedMessages = append(resp.FailedMessages, MessageDeleteFailEntry{
+					ErrorCode:     BatchChunkFailedErrorCode,
+					ErrorMessage:  errs[i].Error(),
+					ReceiptHandle: handle,
+				})
+			}
+			continue
+		}
+		resp.FailedMessages = append(resp.FailedMessages, results[i].FailedMessages...)
+	}
+
+	if failedChunks == len(chunks) {
+		return BatchMessageDeleteErrorResponse{}, err
+	}
+	return resp, nil
+}
+
+// batchDeleteOnce deletes one batch of receipt handles and releases the deleted ones, the
+// error is nil when only some entries failed.
+func (p *MNSQueue) batchDeleteOnce(receiptHandles []string) (resp BatchMessageDeleteErrorResponse, err error) {
 	handlers := ReceiptHandles{}
 
 	for _, handler := range receiptHandles {
@@ -194,12 +360,155 @@ func (p *MNSQueue) BatchDeleteMessage(receiptHandles ...string) (resp BatchMessa
 
 	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, NewBatchOpDecoder(&resp), DELETE, nil, handlers, fmt.Sprintf("queues/%s/%s", p.name, "messages"), nil)
+	if err != nil && !ERR_MNS_BATCH_OP_FAIL.IsEqual(err) {
+		return
+	}
+	err = nil
 
+	failed := map[string]bool{}
+	for _, entry := range resp.FailedMessages {
+		failed[entry.ReceiptHandle] = true
+	}
+	for _, handle := range receiptHandles {
+		if !failed[handle] {
+			p.release(handle)
+		}
+	}
 	return
 }
 
 func (p *MNSQueue) ChangeMessageVisibility(receiptHandle string, visibilityTimeout int64) (resp MessageVisibilityChangeResponse, err error) {
 	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, PUT, nil, nil, fmt.Sprintf("queues/%s/%s?ReceiptHandle=%s&VisibilityTimeout=%d", p.name, "messages", url.QueryEscape(receiptHandle), visibilityTimeout), &resp)
+	if err == nil {
+		p.renewRelease(receiptHandle, resp.ReceiptHandle, resp.NextVisibleTime)
+	}
 	return
 }
+
+func (p *MNSQueue) decodeMessage(resp *MessageReceiveResponse) (err error) {
+	messages := []MessageReceiveResponse{*resp}
+	err = decodeReceivedMessages(p.codecs, messages, p.trackRelease)
+	*resp = messages[0]
+	return
+}
+
+func (p *MNSQueue) decodeBatchMessage(resp *BatchMessageReceiveResponse) (err error) {
+	return decodeReceivedMessages(p.codecs, resp.Messages, p.trackRelease)
+}
+
+func (p *MNSQueue) trackRelease(msg MessageReceiveResponse, releases []func() error) {
+	if msg.ReceiptHandle == "" {
+		return
+	}
+
+	p.releaseLocker.Lock()
+	defer p.releaseLocker.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastPrune) > time.Minute {
+		for handle, r := range p.releases {
+			if now.After(r.expire) {
+				delete(p.releases, handle)
+			}
+		}
+		p.lastPrune = now
+	}
+
+	p.releases[msg.ReceiptHandle] = messageReleases{expire: releaseExpireTime(msg.NextVisibleTime), releases: releases}
+}
+
+func (p *MNSQueue) renewRelease(oldHandle string, newHandle string, nextVisibleTime int64) {
+	p.releaseLocker.Lock()
+	defer p.releaseLocker.Unlock()
+
+	if r, exist := p.releases[oldHandle]; exist {
+		delete(p.releases, oldHandle)
+		r.expire = releaseExpireTime(nextVisibleTime)
+		p.releases[newHandle] = r
+	}
+}
+
+// release runs the cleanup funcs of a deleted message. Cleanup is best effort, resources
+// left behind should be expired by the owner, e.g. through a lifecycle rule of the BlobStore.
+func (p *MNSQueue) release(receiptHandle string) {
+	p.releaseLocker.Lock()
+	r, exist := p.releases[receiptHandle]
+	delete(p.releases, receiptHandle)
+	p.releaseLocker.Unlock()
+
+	if exist {
+		for _, release := range r.releases {
+			release()
+		}
+	}
+}
+
+func releaseExpireTime(nextVisibleTime int64) time.Time {
+	if nextVisibleTime <= 0 {
+		return time.Now().Add(43200 * time.Second)
+	}
+	return time.UnixMilli(nextVisibleTime).Add(time.Minute)
+}
+
+// receiveMessage calls the channel based ReceiveMessage of queue and returns its result.
+func receiveMessage(queue AliMNSQueue, waitseconds ...int64) (resp MessageReceiveResponse, err error) {
+	respChan := make(chan MessageReceiveResponse, 1)
+	errChan := make(chan error, len(waitseconds)+1)
+	queue.ReceiveMessage(respChan, errChan, waitseconds...)
+	select {
+	case resp = <-respChan:
+		return
+	default:
+	}
+	return resp, lastError(errChan)
+}
+
+func peekMessage(queue AliMNSQueue) (resp MessageReceiveResponse, err error) {
+	respChan := make(chan MessageReceiveResponse, 1)
+	errChan := make(chan error, 1)
+	queue.PeekMessage(respChan, errChan)
+	select {
+	case resp = <-respChan:
+		return
+	default:
+	}
+	return resp, lastError(errChan)
+}
+
+func batchReceiveMessage(queue AliMNSQueue, numOfMessages int32, waitseconds ...int64) (resp BatchMessageReceiveResponse, err error) {
+	respChan := make(chan BatchMessageReceiveResponse, 1)
+	errChan := make(chan error, len(waitseconds)+1)
+	queue.BatchReceiveMessage(respChan, errChan, numOfMessages, waitseconds...)
+	select {
+	case resp = <-respChan:
+		return
+	default:
+	}
+	return resp, lastError(errChan)
+}
+
+func batchPeekMessage(queue AliMNSQueue, numOfMessages int32) (resp BatchMessageReceiveResponse, err error) {
+	respChan := make(chan BatchMessageReceiveResponse, 1)
+	errChan := make(chan error, 1)
+	queue.BatchPeekMessage(respChan, errChan, numOfMessages)
+	select {
+	case resp = <-respChan:
+		return
+	default:
+	}
+	return resp, lastError(errChan)
+}
+
+// lastError drains errChan and returns the last error, the receive calls report one error
+// per attempted wait period.
+func lastError(errChan chan error) (err error) {
+	for {
+		select {
+		case e := <-errChan:
+			err = e
+		default:
+			return
+		}
+	}
+}
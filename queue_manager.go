@@ -88,7 +88,7 @@ func checkQueueName(queueName string) (err error) {
 }
 
 func checkDelaySeconds(seconds int32) (err error) {
-	if seconds > 60480 || seconds < 0 {
+	if int64(seconds) > MaxDelaySeconds || seconds < 0 {
 		err = ERR_MNS_DELAY_SECONDS_RANGE_ERROR.New()
 		return
 	}
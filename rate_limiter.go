@@ -0,0 +1,138 @@
+package ali_mns
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RateLimiter is a token bucket which refills rate tokens per second up to burst. Waiters
+// are served in the order they arrived. It is safe for concurrent use, so one limiter can
+// be shared by any number of queues, topics and managers.
+type RateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing qps requests per second with bursts of up to
+// burst requests, a burst less than 1 is raised to 1. A qps not positive means unlimited.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: qps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (p *RateLimiter) Rate() float64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.rate
+}
+
+func (p *RateLimiter) Burst() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return int(p.burst)
+}
+
+// SetRate changes the refill rate, requests which are already waiting keep their turn.
+func (p *RateLimiter) SetRate(qps float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.advance(time.Now())
+	p.rate = qps
+}
+
+// Wait blocks until a request is allowed or ctx is done, in which case its turn is given
+// back and the ctx error is returned.
+func (p *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	if p.rate <= 0 {
+		p.lock.Unlock()
+		return nil
+	}
+	p.advance(time.Now())
+	// every waiter takes a token right away, a negative balance is the queue of waiters
+	// ahead, so the wait time grows with the position
+	p.tokens--
+	var delay time.Duration
+	if p.tokens < 0 {
+		delay = time.Duration(-p.tokens / p.rate * float64(time.Second))
+	}
+	p.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		p.lock.Lock()
+		p.advance(time.Now())
+		p.tokens = math.Min(p.tokens+1, p.burst)
+		p.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (p *RateLimiter) advance(now time.Time) {
+	if !now.After(p.last) {
+		return
+	}
+	if p.rate > 0 {
+		p.tokens = math.Min(p.burst, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	}
+	p.last = now
+}
+
+type rateLimitedClient struct {
+	MNSClient
+	control *RateLimiter
+	data    *RateLimiter
+}
+
+// NewRateLimitedClient returns a client which passes every request through limiter, so
+// that all queues, topics and managers created from it share one account wide limit.
+func NewRateLimitedClient(client MNSClient, limiter *RateLimiter) MNSClient {
+	return &rateLimitedClient{MNSClient: client, control: limiter, data: limiter}
+}
+
+// NewPlaneRateLimitedClient returns a client which passes message requests through data
+// and all other requests, such as creating, listing or subscribing, through control. A
+// nil limiter leaves its requests unlimited.
+func NewPlaneRateLimitedClient(client MNSClient, control *RateLimiter, data *RateLimiter) MNSClient {
+	return &rateLimitedClient{MNSClient: client, control: control, data: data}
+}
+
+func (p *rateLimitedClient) Send(method Method, headers map[string]string, message interface{}, resource string) (*fasthttp.Response, error) {
+	limiter := p.control
+	if isDataPlaneResource(resource) {
+		limiter = p.data
+	}
+	if limiter != nil {
+		limiter.Wait(context.Background())
+	}
+	return p.MNSClient.Send(method, headers, message, resource)
+}
+
+// isDataPlaneResource reports whether resource addresses the messages of a queue or topic.
+func isDataPlaneResource(resource string) bool {
+	path, _, _ := strings.Cut(resource, "?")
+	pieces := strings.Split(path, "/")
+	return len(pieces) == 3 && (pieces[0] == "queues" || pieces[0] == "topics") && pieces[2] == "messages"
+}
@@ -0,0 +1,165 @@
+package ali_mns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReceiveOptions controls a synchronous receive call.
+type ReceiveOptions struct {
+	// WaitSeconds is the long polling wait, 0~30. 0 returns at once.
+	WaitSeconds int64
+	// NumOfMessages is the maximum number of messages of a batch call, 1~16. 0 means
+	// DefaultNumOfMessages.
+	NumOfMessages int32
+}
+
+func (p ReceiveOptions) check() (err error) {
+	if err = checkWaitSeconds(p.WaitSeconds); err != nil {
+		return
+	}
+	if p.NumOfMessages < 0 || p.NumOfMessages > DefaultNumOfMessages {
+		return fmt.Errorf("ali_mns: number of messages is not in range of (1~%d)", DefaultNumOfMessages)
+	}
+	return
+}
+
+// checkWaitSeconds range checks an int64 wait before it is narrowed, so that values beyond
+// int32 can not wrap into the valid range.
+func checkWaitSeconds(waitSeconds int64) error {
+	if waitSeconds < 0 || waitSeconds > 30 {
+		return ERR_MNS_MSG_POOLLING_WAIT_SECONDS_RANGE_ERROR.New()
+	}
+	return checkPollingWaitSeconds(int32(waitSeconds))
+}
+
+func (p ReceiveOptions) numOfMessages() int32 {
+	if p.NumOfMessages == 0 {
+		return DefaultNumOfMessages
+	}
+	return p.NumOfMessages
+}
+
+// AliMNSReceiver is the return value based counterpart of the channel based receive and
+// peek methods of AliMNSQueue, MNSQueue implements both. An empty queue is reported as a
+// nil message or an empty batch, not as ERR_MNS_MESSAGE_NOT_EXIST. When some bodies can
+// not be decoded the messages are returned together with a *MessageBodyDecodeError.
+type AliMNSReceiver interface {
+	Receive(options ReceiveOptions) (msg *MessageReceiveResponse, err error)
+	BatchReceive(options ReceiveOptions) (resp BatchMessageReceiveResponse, err error)
+	Peek() (msg *MessageReceiveResponse, err error)
+	BatchPeek(numOfMessages int32) (resp BatchMessageReceiveResponse, err error)
+}
+
+func (p *MNSQueue) Receive(options ReceiveOptions) (msg *MessageReceiveResponse, err error) {
+	if err = options.check(); err != nil {
+		return
+	}
+	return singleReceiveResult(p.receiveOne(options.WaitSeconds, false))
+}
+
+func (p *MNSQueue) BatchReceive(options ReceiveOptions) (resp BatchMessageReceiveResponse, err error) {
+	if err = options.check(); err != nil {
+		return
+	}
+	return batchReceiveResult(p.receiveBatch(options.numOfMessages(), options.WaitSeconds, false))
+}
+
+func (p *MNSQueue) Peek() (msg *MessageReceiveResponse, err error) {
+	return singleReceiveResult(p.receiveOne(0, true))
+}
+
+func (p *MNSQueue) BatchPeek(numOfMessages int32) (resp BatchMessageReceiveResponse, err error) {
+	options := ReceiveOptions{NumOfMessages: numOfMessages}
+	if err = options.check(); err != nil {
+		return
+	}
+	return batchReceiveResult(p.receiveBatch(options.numOfMessages(), 0, true))
+}
+
+// receiveOne and receiveBatch forward the intermediate hops of SendMessageAt unless they
+// peek, so that receivers never see them.
+func (p *MNSQueue) receiveOne(waitSeconds int64, peek bool) (resp MessageReceiveResponse, err error) {
+	p.qpsMonitor.checkQPS()
+	resource := p.messagesResource(0, waitSeconds, peek)
+	if _, err = send(p.client, p.decoder, GET, nil, nil, resource, &resp); err != nil {
+		return
+	}
+	if err = p.decodeMessage(&resp); err != nil || peek {
+		return
+	}
+	err = p.forwardScheduled(resp, resource)
+	return
+}
+
+func (p *MNSQueue) receiveBatch(numOfMessages int32, waitSeconds int64, peek bool) (resp BatchMessageReceiveResponse, err error) {
+	p.qpsMonitor.checkQPS()
+	resource := p.messagesResource(numOfMessages, waitSeconds, peek)
+	if _, err = send(p.client, p.decoder, GET, nil, nil, resource, &resp); err != nil {
+		return
+	}
+	err = p.decodeBatchMessage(&resp)
+	if peek || (err != nil && !isMessageBodyDecodeError(err)) {
+		return
+	}
+	err = p.forwardScheduledBatch(&resp, err, resource)
+	return
+}
+
+func (p *MNSQueue) messagesResource(numOfMessages int32, waitSeconds int64, peek bool) string {
+	params := []string{}
+	if numOfMessages > 0 {
+		params = append(params, fmt.Sprintf("numOfMessages=%d", numOfMessages))
+	}
+	if waitSeconds > 0 {
+		params = append(params, fmt.Sprintf("waitseconds=%d", waitSeconds))
+	}
+	if peek {
+		params = append(params, "peekonly=true")
+	}
+
+	resource := fmt.Sprintf("queues/%s/%s", p.name, "messages")
+	if len(params) > 0 {
+		resource += "?" + strings.Join(params, "&")
+	}
+	return resource
+}
+
+func singleReceiveResult(resp MessageReceiveResponse, err error) (*MessageReceiveResponse, error) {
+	switch {
+	case err == nil, isMessageBodyDecodeError(err):
+		return &resp, err
+	case ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err):
+		return nil, nil
+	}
+	return nil, err
+}
+
+func batchReceiveResult(resp BatchMessageReceiveResponse, err error) (BatchMessageReceiveResponse, error) {
+	if ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+		return BatchMessageReceiveResponse{}, nil
+	}
+	return resp, err
+}
+
+// receiveAttempts returns the wait seconds of the requests made by the channel based
+// receive calls: one per positive value, or a single one without waiting when none given.
+func receiveAttempts(waitseconds []int64) []int64 {
+	if waitseconds == nil {
+		return []int64{0}
+	}
+
+	attempts := []int64{}
+	for _, waitsecond := range waitseconds {
+		if waitsecond > 0 {
+			attempts = append(attempts, waitsecond)
+		}
+	}
+	return attempts
+}
+
+func isMessageBodyDecodeError(err error) bool {
+	var decodeErr *MessageBodyDecodeError
+	return errors.As(err, &decodeErr)
+}
@@ -0,0 +1,60 @@
+package ali_mns
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	minVisibilityTimeout time.Duration = time.Second
+	maxVisibilityTimeout time.Duration = 43200 * time.Second
+)
+
+// RedeliveryBackoff computes how long a failed message stays invisible before it is
+// delivered again: base * 2^DequeueCount, capped at max.
+type RedeliveryBackoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// NewRedeliveryBackoff creates a backoff, base and max must be within the 1~43200 seconds
+// a visibility timeout allows.
+func NewRedeliveryBackoff(base time.Duration, max time.Duration) (*RedeliveryBackoff, error) {
+	if base < minVisibilityTimeout || base > maxVisibilityTimeout {
+		return nil, fmt.Errorf("ali_mns: redelivery base is not in range of (1s~43200s)")
+	}
+	if max < base || max > maxVisibilityTimeout {
+		return nil, fmt.Errorf("ali_mns: redelivery max is not in range of (base~43200s)")
+	}
+	return &RedeliveryBackoff{base: base, max: max}, nil
+}
+
+// Delay returns the redelivery delay of a message received dequeueCount times.
+func (p *RedeliveryBackoff) Delay(dequeueCount int64) time.Duration {
+	delay := p.base
+	for i := int64(0); i < dequeueCount && delay < p.max; i++ {
+		delay *= 2
+	}
+	if delay > p.max {
+		delay = p.max
+	}
+	return delay
+}
+
+// Nack makes msg visible again after the delay for its DequeueCount.
+func (p *RedeliveryBackoff) Nack(queue AliMNSQueue, msg MessageReceiveResponse) (resp MessageVisibilityChangeResponse, err error) {
+	seconds := visibilitySeconds(p.Delay(msg.DequeueCount))
+	if err = checkVisibilityTimeout(int32(seconds)); err != nil {
+		return
+	}
+	return queue.ChangeMessageVisibility(msg.ReceiptHandle, seconds)
+}
+
+// visibilitySeconds rounds d up to whole seconds of at least 1.
+func visibilitySeconds(d time.Duration) int64 {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
@@ -0,0 +1,152 @@
+package ali_mns
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxDelaySeconds is the longest delay of a queue message.
+	MaxDelaySeconds int64 = 60480
+
+	// ScheduledDeliverAtProperty carries the delivery time in unix milliseconds of a message
+	// sent by SendMessageAt while it is still re-enqueued.
+	ScheduledDeliverAtProperty = "mns-scheduled-deliver-at"
+	// ScheduledMessageIdProperty is the message id returned by SendMessageAt, kept on the
+	// re-enqueued copies.
+	ScheduledMessageIdProperty = "mns-scheduled-message-id"
+
+	// scheduledTolerance absorbs the clock skew between client and server, a hop which
+	// arrives earlier than that is delivered anyway.
+	scheduledTolerance = time.Second
+)
+
+// SendMessageAt sends message so that it becomes visible at deliverAt. A time beyond the
+// maximum delay is reached by re-enqueueing the message with the maximum delay, which the
+// receive methods of MNSQueue do transparently; receivers of other AliMNSQueue
+// implementations call ForwardScheduledMessage. Peeked messages are not forwarded. The
+// DelaySeconds of message is ignored.
+func (p *MNSQueue) SendMessageAt(message MessageSendRequest, deliverAt time.Time) (resp MessageSendResponse, err error) {
+	return SendMessageAt(p, message, deliverAt)
+}
+
+// SendMessageAt is MNSQueue.SendMessageAt for any AliMNSQueue.
+func SendMessageAt(queue AliMNSQueue, message MessageSendRequest, deliverAt time.Time) (resp MessageSendResponse, err error) {
+	delay := time.Until(deliverAt)
+	if delay <= 0 {
+		message.DelaySeconds = 0
+		return queue.SendMessage(message)
+	}
+
+	// round up, the message must not arrive before deliverAt
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	if seconds <= MaxDelaySeconds {
+		message.DelaySeconds = seconds
+		return queue.SendMessage(message)
+	}
+
+	message.DelaySeconds = MaxDelaySeconds
+	message.UserProperties = append(MessageProperties{}, message.UserProperties...)
+	message.UserProperties.SetTyped(ScheduledDeliverAtProperty, strconv.FormatInt(deliverAt.UnixMilli(), 10), NUMBER_PROPERTY)
+	return queue.SendMessage(message)
+}
+
+// ScheduledDeliverAt returns the delivery time of a message sent by SendMessageAt, ok is
+// false for messages which were not re-enqueued.
+func ScheduledDeliverAt(msg MessageReceiveResponse) (deliverAt time.Time, ok bool) {
+	value, exist := msg.UserProperties.Get(ScheduledDeliverAtProperty)
+	if !exist {
+		return
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+	return time.UnixMilli(millis), true
+}
+
+// ForwardScheduledMessage re-enqueues msg and deletes it from queue when it is an
+// intermediate hop of SendMessageAt, in which case it must not be handled. It reports
+// whether msg was such a hop.
+func ForwardScheduledMessage(queue AliMNSQueue, msg MessageReceiveResponse) (forwarded bool, err error) {
+	deliverAt, ok := ScheduledDeliverAt(msg)
+	if !ok || time.Until(deliverAt) <= scheduledTolerance {
+		return false, nil
+	}
+
+	request := MessageSendRequest{
+		MessageBody:    msg.MessageBody,
+		Priority:       msg.Priority,
+		UserProperties: append(MessageProperties{}, msg.UserProperties...),
+	}
+	if _, exist := request.UserProperties.Get(ScheduledMessageIdProperty); !exist {
+		request.UserProperties.Set(ScheduledMessageIdProperty, msg.MessageId)
+	}
+	request.UserProperties.Delete(ScheduledDeliverAtProperty)
+
+	if _, err = SendMessageAt(queue, request, deliverAt); err != nil {
+		return true, fmt.Errorf("ali_mns: re-enqueue scheduled message failed, %w", err)
+	}
+	return true, queue.DeleteMessage(msg.ReceiptHandle)
+}
+
+// forwardScheduled forwards resp when it is an intermediate hop of SendMessageAt and then
+// reports the queue as empty, or the forwarding error.
+func (p *MNSQueue) forwardScheduled(resp MessageReceiveResponse, resource string) error {
+	forwarded, err := ForwardScheduledMessage(p, resp)
+	if !forwarded {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return newMessageNotExistError(resource)
+}
+
+// forwardScheduledBatch forwards the intermediate hops of SendMessageAt among the received
+// messages and leaves them out, err is the result of decoding them. A hop which could not be
+// forwarded is left out as well and reappears after its visibility timeout; its error is
+// only returned when no message is left.
+func (p *MNSQueue) forwardScheduledBatch(resp *BatchMessageReceiveResponse, err error, resource string) error {
+	var decodeErr *MessageBodyDecodeError
+	errors.As(err, &decodeErr)
+
+	messages := []MessageReceiveResponse{}
+	failed := map[int]error{}
+	var forwardErr error
+	for i, msg := range resp.Messages {
+		if decodeErr != nil {
+			if e, exist := decodeErr.Errors[i]; exist {
+				// the properties are intact, but the body can not be re-sent
+				failed[len(messages)] = e
+				messages = append(messages, msg)
+				continue
+			}
+		}
+
+		forwarded, e := ForwardScheduledMessage(p, msg)
+		if e != nil && forwardErr == nil {
+			forwardErr = e
+		}
+		if !forwarded {
+			messages = append(messages, msg)
+		}
+	}
+	resp.Messages = messages
+
+	switch {
+	case decodeErr != nil:
+		return &MessageBodyDecodeError{Messages: messages, Errors: failed}
+	case len(messages) > 0:
+		return nil
+	case forwardErr != nil:
+		return forwardErr
+	}
+	return newMessageNotExistError(resource)
+}
+
+func newMessageNotExistError(resource string) error {
+	return ParseError(ErrorResponse{Code: "MessageNotExist", Message: "Message not exist."}, resource)
+}
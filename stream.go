@@ -0,0 +1,199 @@
+package ali_mns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultStreamWaitSeconds int64         = 10
+	DefaultStreamMinBackoff  time.Duration = 100 * time.Millisecond
+	DefaultStreamMaxBackoff  time.Duration = 10 * time.Second
+)
+
+// StreamOptions controls MNSQueue.Messages.
+type StreamOptions struct {
+	// ReceiveOptions of every poll, a WaitSeconds of 0 means DefaultStreamWaitSeconds.
+	ReceiveOptions
+	// MinBackoff is the pause after a failed or empty poll, it doubles with every further
+	// one up to MaxBackoff and is reset by a poll returning messages.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ErrorHandler receives poll errors, bodies which could not be decoded and failures of
+	// forwarding scheduled messages. It may be nil.
+	ErrorHandler func(err error)
+}
+
+func (p *StreamOptions) withDefaults() {
+	if p.WaitSeconds == 0 {
+		p.WaitSeconds = DefaultStreamWaitSeconds
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = DefaultStreamMinBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = DefaultStreamMaxBackoff
+		if p.MaxBackoff < p.MinBackoff {
+			p.MaxBackoff = p.MinBackoff
+		}
+	}
+}
+
+// Delivery is a message received by MNSQueue.Messages. Exactly one of Ack or Nack settles
+// it, ExtendVisibility may be called any number of times before.
+type Delivery struct {
+	MessageReceiveResponse
+	state *deliveryState
+}
+
+type deliveryState struct {
+	queue AliMNSQueue
+
+	lock          sync.Mutex
+	receiptHandle string
+	settled       bool
+}
+
+func newDelivery(queue AliMNSQueue, msg MessageReceiveResponse) Delivery {
+	return Delivery{MessageReceiveResponse: msg, state: &deliveryState{queue: queue, receiptHandle: msg.ReceiptHandle}}
+}
+
+// Handle returns the current receipt handle, which changes with ExtendVisibility.
+func (p Delivery) Handle() string {
+	p.state.lock.Lock()
+	defer p.state.lock.Unlock()
+	return p.state.receiptHandle
+}
+
+// Ack deletes the message from the queue.
+func (p Delivery) Ack() error {
+	p.state.lock.Lock()
+	defer p.state.lock.Unlock()
+
+	if p.state.settled {
+		return fmt.Errorf("ali_mns: delivery of message %s is already settled", p.MessageId)
+	}
+	if err := p.state.queue.DeleteMessage(p.state.receiptHandle); err != nil {
+		return err
+	}
+	p.state.settled = true
+	return nil
+}
+
+// Nack makes the message visible again after delay, at least 1 second.
+func (p Delivery) Nack(delay time.Duration) error {
+	p.state.lock.Lock()
+	defer p.state.lock.Unlock()
+
+	if p.state.settled {
+		return fmt.Errorf("ali_mns: delivery of message %s is already settled", p.MessageId)
+	}
+	if err := p.changeVisibility(delay); err != nil {
+		return err
+	}
+	p.state.settled = true
+	return nil
+}
+
+// ExtendVisibility keeps the message invisible for d from now on, at least 1 second.
+func (p Delivery) ExtendVisibility(d time.Duration) error {
+	p.state.lock.Lock()
+	defer p.state.lock.Unlock()
+
+	if p.state.settled {
+		return fmt.Errorf("ali_mns: delivery of message %s is already settled", p.MessageId)
+	}
+	return p.changeVisibility(d)
+}
+
+func (p Delivery) changeVisibility(d time.Duration) error {
+	seconds := visibilitySeconds(d)
+	if seconds > int64(maxVisibilityTimeout/time.Second) {
+		return ERR_MNS_MSG_VISIBILITY_TIMEOUT_RANGE_ERROR.New()
+	}
+
+	resp, err := p.state.queue.ChangeMessageVisibility(p.state.receiptHandle, seconds)
+	if err != nil {
+		return err
+	}
+	p.state.receiptHandle = resp.ReceiptHandle
+	return nil
+}
+
+// Messages long polls the queue until ctx is done and yields the received messages on
+// the returned channel, which is closed afterwards. Messages still unsettled when the
+// reader stops become visible again after their visibility timeout. Intermediate hops of
+// SendMessageAt are forwarded and not yielded. Invalid options are reported to the error
+// handler and close the channel at once.
+func (p *MNSQueue) Messages(ctx context.Context, options StreamOptions) <-chan Delivery {
+	out := make(chan Delivery)
+	options.withDefaults()
+
+	report := func(err error) {
+		if options.ErrorHandler != nil {
+			options.ErrorHandler(err)
+		}
+	}
+
+	go func() {
+		defer close(out)
+
+		if err := options.check(); err != nil {
+			report(err)
+			return
+		}
+
+		backoff := time.Duration(0)
+		for ctx.Err() == nil {
+			messages, err := p.poll(options.ReceiveOptions, report)
+			if err != nil {
+				report(err)
+			}
+
+			if len(messages) == 0 {
+				if backoff == 0 {
+					backoff = options.MinBackoff
+				} else if backoff *= 2; backoff > options.MaxBackoff {
+					backoff = options.MaxBackoff
+				}
+				if !sleepContext(ctx, backoff) {
+					return
+				}
+				continue
+			}
+			backoff = 0
+
+			for _, msg := range messages {
+				select {
+				case out <- newDelivery(p, msg):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// poll receives one batch, leaving out bodies which could not be decoded.
+func (p *MNSQueue) poll(options ReceiveOptions, report func(error)) ([]MessageReceiveResponse, error) {
+	resp, err := p.BatchReceive(options)
+
+	var decodeErr *MessageBodyDecodeError
+	if !errors.As(err, &decodeErr) {
+		return resp.Messages, err
+	}
+
+	messages := []MessageReceiveResponse{}
+	for i, msg := range decodeErr.Messages {
+		if _, failed := decodeErr.Errors[i]; failed {
+			report(&ConsumerError{Message: msg, Err: decodeErr.Errors[i]})
+		} else {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestAcknowledgerFlushesFullBatch(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("ack-queue", client)
+	for i := 0; i < 20; i++ {
+		client.enqueue("ack-queue", fmt.Sprintf("body-%d", i))
+	}
+
+	ack, err := ali_mns.NewAcknowledger(queue, ali_mns.WithAckLinger(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create acknowledger: %v", err)
+	}
+
+	resp, _ := batchReceive(queue, 16)
+	results := []<-chan error{}
+	for _, msg := range resp.Messages {
+		results = append(results, ack.Ack(msg.ReceiptHandle))
+	}
+
+	// 满 16 条立即批量删除，不等待 linger
+	for i, result := range results {
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Errorf("Handle %d: unexpected error %v", i, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for full batch to be flushed")
+		}
+	}
+	if client.requestCount("DELETE") != 1 {
+		t.Errorf("Expected 1 batch delete request, got %d", client.requestCount("DELETE"))
+	}
+	if len(client.messages("ack-queue")) != 4 {
+		t.Errorf("Expected 4 remaining messages, got %d", len(client.messages("ack-queue")))
+	}
+}
+
+func TestAcknowledgerLingerAndFailures(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("ack-queue", client)
+	client.enqueue("ack-queue", "body")
+
+	var reported int32
+	ack, _ := ali_mns.NewAcknowledger(queue, ali_mns.WithAckLinger(50*time.Millisecond),
+		ali_mns.WithAckErrorHandler(func(err *ali_mns.AckError) {
+			atomic.AddInt32(&reported, 1)
+		}))
+
+	msg, _ := receiveOne(queue)
+	ok := ack.Ack(msg.ReceiptHandle)
+	invalid := ack.Ack("invalid-handle")
+
+	if err := <-ok; err != nil {
+		t.Errorf("Expected valid handle to be deleted, got %v", err)
+	}
+
+	// 批量删除中失败的句柄单独上报
+	var ackErr *ali_mns.AckError
+	if err := <-invalid; !errors.As(err, &ackErr) || ackErr.ErrorCode != "ReceiptHandleError" || ackErr.ReceiptHandle != "invalid-handle" {
+		t.Errorf("Expected ReceiptHandleError for invalid handle, got %v", err)
+	}
+	if atomic.LoadInt32(&reported) != 1 {
+		t.Errorf("Expected 1 reported failure, got %d", reported)
+	}
+}
+
+func TestAcknowledgerClose(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("ack-queue", client)
+	client.enqueue("ack-queue", "body")
+
+	ack, _ := ali_mns.NewAcknowledger(queue, ali_mns.WithAckLinger(time.Hour))
+	msg, _ := receiveOne(queue)
+	ack.Ack(msg.ReceiptHandle)
+
+	// 关闭时刷新缓冲区
+	ack.Close()
+	if len(client.messages("ack-queue")) != 0 {
+		t.Error("Expected buffered handle to be deleted on close")
+	}
+	if err := <-ack.Ack("late"); err == nil {
+		t.Error("Expected ack after close to fail")
+	}
+}
+
+func TestConsumerWithAcknowledger(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("ack-queue", client)
+	for i := 0; i < 32; i++ {
+		client.enqueue("ack-queue", fmt.Sprintf("body-%d", i))
+	}
+
+	ack, _ := ali_mns.NewAcknowledger(queue, ali_mns.WithAckLinger(20*time.Millisecond))
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		return nil
+	}, ali_mns.WithConsumerConcurrency(16), ali_mns.WithConsumerWaitSeconds(0),
+		ali_mns.WithConsumerErrorDelay(10*time.Millisecond), ali_mns.WithConsumerAcknowledger(ack))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("ack-queue")) == 0 })
+
+	if n := client.requestCount("DELETE"); n >= 32 {
+		t.Errorf("Expected deletes to be batched, got %d requests", n)
+	}
+}
+
+func TestConsumerWaitsForAcknowledger(t *testing.T) {
+	client := &slowClient{mockMNSClient: newMockMNSClient(), delay: 50 * time.Millisecond}
+	queue, _ := ali_mns.NewMNSQueue("ack-queue", client)
+	for i := 0; i < 3; i++ {
+		client.enqueue("ack-queue", fmt.Sprintf("body-%d", i))
+	}
+
+	ack, _ := ali_mns.NewAcknowledger(queue, ali_mns.WithAckLinger(time.Hour))
+	var handled int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerAcknowledger(ack))
+
+	// Run 返回前缓冲的句柄已被删除，且确认器仍可使用
+	runConsumer(t, consumer, func() bool { return atomic.LoadInt32(&handled) == 3 })
+	if n := len(client.messages("ack-queue")); n != 0 {
+		t.Errorf("Expected buffered handles to be deleted when Run returns, %d left", n)
+	}
+	client.enqueue("ack-queue", "later")
+	msg, _ := receiveOne(queue)
+	ack.Ack(msg.ReceiptHandle)
+	if err := ack.FlushWait(context.Background()); err != nil || len(client.messages("ack-queue")) != 0 {
+		t.Errorf("Expected acknowledger to stay open, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestAdaptiveRateLimiterAIMD(t *testing.T) {
+	client := newMockMNSClient()
+	limiter, err := ali_mns.NewAdaptiveRateLimiter(10, 100, ali_mns.WithAdaptiveInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	queue, _ := ali_mns.NewMNSQueueWithOptions("adaptive-queue", client, ali_mns.WithAdaptiveRateLimiter(limiter))
+	time.Sleep(30 * time.Millisecond)
+
+	// 收到 QpsLimitExceeded 时成倍降低速率，同一间隔内只降低一次
+	client.injectError(403, "QpsLimitExceeded")
+	client.injectError(403, "QpsLimitExceeded")
+	if _, err = queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"}); !ali_mns.ERR_MNS_QPS_LIMIT_EXCEEDED.IsEqual(err) {
+		t.Fatalf("Expected qps limit error, got %v", err)
+	}
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"})
+	if rate := queue.QPSMonitor().RateLimiter().Rate(); rate != 50 {
+		t.Errorf("Expected rate 50 after throttling, got %v", rate)
+	}
+
+	// 成功后每个间隔增加一步
+	time.Sleep(30 * time.Millisecond)
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"})
+	if rate := limiter.Rate(); rate != 60 {
+		t.Errorf("Expected rate 60 after probing, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimiterBounds(t *testing.T) {
+	limiter, _ := ali_mns.NewAdaptiveRateLimiter(10, 20, ali_mns.WithAdaptiveInterval(time.Millisecond))
+	for i := 0; i < 5; i++ {
+		time.Sleep(2 * time.Millisecond)
+		limiter.Throttled()
+	}
+	if rate := limiter.Rate(); rate != 10 {
+		t.Errorf("Expected rate not below the minimum, got %v", rate)
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(2 * time.Millisecond)
+		limiter.Succeeded()
+	}
+	if rate := limiter.Rate(); rate != 20 {
+		t.Errorf("Expected rate not above the maximum, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimiterMixedSignals(t *testing.T) {
+	limiter, _ := ali_mns.NewAdaptiveRateLimiter(10, 1000, ali_mns.WithAdaptiveInterval(10*time.Millisecond),
+		ali_mns.WithAdaptiveInitialRate(500))
+
+	// 大部分请求成功但持续有请求被限流时，速率应下降而不是上升
+	for i := 0; i < 200; i++ {
+		if i%10 == 0 {
+			limiter.Throttled()
+		} else {
+			limiter.Succeeded()
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rate := limiter.Rate(); rate >= 500 {
+		t.Errorf("Expected rate below 500 while throttled, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimiterTopicRate(t *testing.T) {
+	client := newMockMNSClient()
+	limiter, _ := ali_mns.NewAdaptiveRateLimiter(10, 100, ali_mns.WithAdaptiveInterval(time.Millisecond))
+	topic, _ := ali_mns.NewMNSTopicWithOptions("adaptive-topic", client, ali_mns.WithAdaptiveRateLimiter(limiter))
+
+	// 主题同样可以查询当前速率
+	time.Sleep(2 * time.Millisecond)
+	client.injectError(403, "QpsLimitExceeded")
+	topic.PublishMessage(ali_mns.MessagePublishRequest{MessageBody: "body"})
+	if rate := topic.(*ali_mns.MNSTopic).QPSMonitor().RateLimiter().Rate(); rate != 50 {
+		t.Errorf("Expected topic rate 50 after throttling, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimitedClient(t *testing.T) {
+	mock := newMockMNSClient()
+	limiter, _ := ali_mns.NewAdaptiveRateLimiter(5, 100, ali_mns.WithAdaptiveInterval(time.Millisecond))
+	client := ali_mns.NewAdaptiveRateLimitedClient(mock, limiter)
+	topic, _ := ali_mns.NewMNSTopic("adaptive-topic", client)
+
+	time.Sleep(2 * time.Millisecond)
+	mock.injectError(403, "QpsLimitExceeded")
+	topic.PublishMessage(ali_mns.MessagePublishRequest{MessageBody: "body"})
+	if rate := limiter.Rate(); rate != 50 {
+		t.Errorf("Expected client level limiter to be throttled, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimiterBatchEntryThrottled(t *testing.T) {
+	client := newMockMNSClient()
+	limiter, _ := ali_mns.NewAdaptiveRateLimiter(10, 100, ali_mns.WithAdaptiveInterval(time.Millisecond))
+	queue, _ := ali_mns.NewMNSQueueWithOptions("adaptive-queue", client, ali_mns.WithAdaptiveRateLimiter(limiter))
+
+	// 批量请求中其他错误码的失败不算限流
+	time.Sleep(2 * time.Millisecond)
+	queue.BatchSendMessage(ali_mns.MessageSendRequest{MessageBody: "ok"}, ali_mns.MessageSendRequest{MessageBody: "fail:InternalError"})
+	if rate := limiter.Rate(); rate != 100 {
+		t.Errorf("Expected rate unchanged, got %v", rate)
+	}
+
+	// 批量请求中任一条目被限流时降低速率
+	time.Sleep(2 * time.Millisecond)
+	queue.BatchSendMessage(ali_mns.MessageSendRequest{MessageBody: "ok"}, ali_mns.MessageSendRequest{MessageBody: "fail:QpsLimitExceeded"})
+	if rate := limiter.Rate(); rate != 50 {
+		t.Errorf("Expected rate 50 after a throttled entry, got %v", rate)
+	}
+}
+
+func TestAdaptiveRateLimiterInvalidOptions(t *testing.T) {
+	if _, err := ali_mns.NewAdaptiveRateLimiter(0, 10); err == nil {
+		t.Error("Expected error for min qps 0")
+	}
+	if _, err := ali_mns.NewAdaptiveRateLimiter(10, 5); err == nil {
+		t.Error("Expected error for max below min")
+	}
+	if _, err := ali_mns.NewAdaptiveRateLimiter(1, 10, ali_mns.WithAdaptiveDecrease(1)); err == nil {
+		t.Error("Expected error for decrease factor 1")
+	}
+	if _, err := ali_mns.NewAdaptiveRateLimiter(1, 10, ali_mns.WithAdaptiveInitialRate(20)); err == nil {
+		t.Error("Expected error for initial rate above max")
+	}
+}
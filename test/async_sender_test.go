@@ -0,0 +1,277 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+	"github.com/valyala/fasthttp"
+)
+
+// gatedClient 在 gate 关闭前阻塞所有请求，entered 在每个请求到达时收到通知
+type gatedClient struct {
+	*mockMNSClient
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func newGatedClient() *gatedClient {
+	return &gatedClient{mockMNSClient: newMockMNSClient(), gate: make(chan struct{}), entered: make(chan struct{}, 100)}
+}
+
+func (p *gatedClient) Send(method ali_mns.Method, headers map[string]string, message interface{}, resource string) (*fasthttp.Response, error) {
+	p.entered <- struct{}{}
+	<-p.gate
+	return p.mockMNSClient.Send(method, headers, message, resource)
+}
+
+func TestAsyncSenderSendAndPublish(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("async-queue", client)
+	topic, _ := ali_mns.NewMNSTopic("async-topic", client)
+	sender, err := ali_mns.NewAsyncSender(ali_mns.WithAsyncWorkers(4))
+	if err != nil {
+		t.Fatalf("Failed to create sender: %v", err)
+	}
+	defer sender.Close()
+
+	var callbacks int32
+	callback := func(resp ali_mns.MessageSendResponse, err error) {
+		if err == nil && resp.MessageId != "" {
+			atomic.AddInt32(&callbacks, 1)
+		}
+	}
+	futures := []*ali_mns.SendFuture{}
+	for i := 0; i < 20; i++ {
+		future, err := sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "body"}, callback)
+		if err != nil {
+			t.Fatalf("Failed to submit: %v", err)
+		}
+		futures = append(futures, future)
+	}
+	for i := 0; i < 5; i++ {
+		future, _ := sender.PublishMessageAsync(topic, ali_mns.MessagePublishRequest{MessageBody: "body"}, callback)
+		futures = append(futures, future)
+	}
+
+	if err = sender.Flush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	// Flush 返回时所有发送都已完成
+	for _, future := range futures {
+		select {
+		case <-future.Done():
+		default:
+			t.Fatal("Expected all futures to be resolved after flush")
+		}
+	}
+	if n := len(client.messages("async-queue")); n != 20 {
+		t.Errorf("Expected 20 messages, got %d", n)
+	}
+	if n := atomic.LoadInt32(&callbacks); n != 25 {
+		t.Errorf("Expected 25 callbacks, got %d", n)
+	}
+}
+
+func TestAsyncSenderBackpressure(t *testing.T) {
+	for _, policy := range []ali_mns.BackpressurePolicy{ali_mns.BACKPRESSURE_DROP, ali_mns.BACKPRESSURE_ERROR, ali_mns.BACKPRESSURE_BLOCK} {
+		client := newGatedClient()
+		queue, _ := ali_mns.NewMNSQueue("async-queue", client)
+		sender, _ := ali_mns.NewAsyncSender(ali_mns.WithAsyncWorkers(1), ali_mns.WithAsyncQueueSize(1), ali_mns.WithAsyncBackpressure(policy))
+
+		// 第一条占用唯一的工作协程，第二条占满队列
+		sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "1"})
+		<-client.entered
+		sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "2"})
+
+		result := make(chan error, 1)
+		var future *ali_mns.SendFuture
+		go func() {
+			var err error
+			future, err = sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "3"})
+			result <- err
+		}()
+
+		switch policy {
+		case ali_mns.BACKPRESSURE_DROP:
+			if err := <-result; err != nil {
+				t.Fatalf("Expected dropped message not to return error, got %v", err)
+			}
+			if _, err := future.Result(); !ali_mns.ERR_MNS_ASYNC_QUEUE_FULL.IsEqual(err) {
+				t.Errorf("Expected queue full error on future, got %v", err)
+			}
+			if sender.Dropped() != 1 {
+				t.Errorf("Expected 1 dropped message, got %d", sender.Dropped())
+			}
+		case ali_mns.BACKPRESSURE_ERROR:
+			if err := <-result; !ali_mns.ERR_MNS_ASYNC_QUEUE_FULL.IsEqual(err) {
+				t.Errorf("Expected queue full error, got %v", err)
+			}
+		case ali_mns.BACKPRESSURE_BLOCK:
+			select {
+			case <-result:
+				t.Error("Expected submit to block while the queue is full")
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+
+		close(client.gate)
+		sender.Flush(context.Background())
+		sender.Close()
+
+		expected := 2
+		if policy == ali_mns.BACKPRESSURE_BLOCK {
+			expected = 3
+		}
+		if n := len(client.messages("async-queue")); n != expected {
+			t.Errorf("%s: expected %d messages, got %d", policy, expected, n)
+		}
+	}
+}
+
+func TestAsyncSenderFlushTimeoutAndClose(t *testing.T) {
+	client := newGatedClient()
+	queue, _ := ali_mns.NewMNSQueue("async-queue", client)
+	sender, _ := ali_mns.NewAsyncSender()
+
+	future, _ := sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "body"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sender.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected flush to time out, got %v", err)
+	}
+
+	// 关闭时等待已提交的消息发送完成
+	close(client.gate)
+	sender.Close()
+	if _, err := future.Result(); err != nil {
+		t.Errorf("Expected message to be sent before close returned, got %v", err)
+	}
+	if _, err := sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "body"}); err == nil {
+		t.Error("Expected error after close")
+	}
+}
+
+func TestAsyncSenderOnQueueAndTopic(t *testing.T) {
+	client := newMockMNSClient()
+	sender, _ := ali_mns.NewAsyncSender()
+	defer sender.Close()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("async-queue", client, ali_mns.WithAsyncSender(sender))
+	topic, _ := ali_mns.NewMNSTopicWithOptions("async-topic", client, ali_mns.WithAsyncSender(sender))
+
+	// 队列和主题使用同一个发送池
+	queueFuture, err := queue.(*ali_mns.MNSQueue).SendMessageAsync(ali_mns.MessageSendRequest{MessageBody: "body"})
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	topicFuture, err := topic.(*ali_mns.MNSTopic).PublishMessageAsync(ali_mns.MessagePublishRequest{MessageBody: "body"})
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	sender.Flush(context.Background())
+	if _, err = queueFuture.Result(); err != nil {
+		t.Errorf("Failed to send: %v", err)
+	}
+	if _, err = topicFuture.Result(); err != nil {
+		t.Errorf("Failed to publish: %v", err)
+	}
+
+	// 未配置发送池时返回错误
+	plain, _ := ali_mns.NewMNSQueue("async-queue", client)
+	if _, err = plain.(*ali_mns.MNSQueue).SendMessageAsync(ali_mns.MessageSendRequest{MessageBody: "body"}); err == nil {
+		t.Error("Expected error without async sender")
+	}
+}
+
+func TestAsyncSenderCallbackPanic(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("async-queue", client)
+	reported := make(chan error, 1)
+	sender, _ := ali_mns.NewAsyncSender(ali_mns.WithAsyncErrorHandler(func(err error) { reported <- err }))
+	defer sender.Close()
+
+	// 回调的 panic 交给错误处理函数，future 仍然完成
+	future, _ := sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "body"}, func(resp ali_mns.MessageSendResponse, err error) {
+		panic("callback failed")
+	})
+	if _, err := future.Result(); err != nil {
+		t.Errorf("Expected message to be sent, got %v", err)
+	}
+	select {
+	case err := <-reported:
+		if !strings.Contains(err.Error(), "callback failed") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected callback panic to be reported")
+	}
+}
+
+// slowClient 为每个请求增加固定延迟
+type slowClient struct {
+	*mockMNSClient
+	delay time.Duration
+}
+
+func (p *slowClient) Send(method ali_mns.Method, headers map[string]string, message interface{}, resource string) (*fasthttp.Response, error) {
+	time.Sleep(p.delay)
+	return p.mockMNSClient.Send(method, headers, message, resource)
+}
+
+func TestAsyncSenderFlushUnderContinuousTraffic(t *testing.T) {
+	client := &slowClient{mockMNSClient: newMockMNSClient(), delay: 5 * time.Millisecond}
+	queue, _ := ali_mns.NewMNSQueue("async-queue", client)
+	sender, _ := ali_mns.NewAsyncSender(ali_mns.WithAsyncWorkers(2), ali_mns.WithAsyncQueueSize(4))
+	defer sender.Close()
+
+	futures := []*ali_mns.SendFuture{}
+	for i := 0; i < 6; i++ {
+		future, _ := sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "before"})
+		futures = append(futures, future)
+	}
+
+	// Flush 期间持续有新消息提交，发送池始终不空闲
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				sender.SendMessageAsync(queue, ali_mns.MessageSendRequest{MessageBody: "during"})
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := sender.Flush(ctx); err != nil {
+		t.Fatalf("Expected flush to return under continuous traffic, got %v", err)
+	}
+	for _, future := range futures {
+		select {
+		case <-future.Done():
+		default:
+			t.Fatal("Expected messages submitted before flush to be completed")
+		}
+	}
+}
+
+func TestAsyncSenderInvalidOptions(t *testing.T) {
+	if _, err := ali_mns.NewAsyncSender(ali_mns.WithAsyncWorkers(0)); err == nil {
+		t.Error("Expected error for 0 workers")
+	}
+	if _, err := ali_mns.NewAsyncSender(ali_mns.WithAsyncBackpressure("SPILL")); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
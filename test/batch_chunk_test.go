@@ -0,0 +1,113 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestBatchSendChunksByCount(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("chunk-queue", client)
+
+	messages := []ali_mns.MessageSendRequest{}
+	for i := 0; i < 40; i++ {
+		messages = append(messages, ali_mns.MessageSendRequest{MessageBody: fmt.Sprintf("body-%d", i)})
+	}
+	resp, err := queue.BatchSendMessage(messages...)
+	if err != nil {
+		t.Fatalf("Failed to batch send: %v", err)
+	}
+
+	// 40 条消息拆分为 16 + 16 + 8 三个请求
+	if n := client.requestCount("POST"); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+	bodies := map[string]string{}
+	for _, m := range client.messages("chunk-queue") {
+		bodies[m.id] = m.body
+	}
+	if len(resp.Messages) != 40 {
+		t.Fatalf("Expected 40 entries, got %d", len(resp.Messages))
+	}
+	for i, entry := range resp.Messages {
+		if bodies[entry.MessageId] != fmt.Sprintf("body-%d", i) {
+			t.Errorf("Entry %d: message id %s belongs to %q", i, entry.MessageId, bodies[entry.MessageId])
+		}
+	}
+}
+
+func TestBatchSendChunksBySize(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("chunk-queue", client)
+
+	body := strings.Repeat("x", 30000)
+	if _, err := queue.BatchSendMessage(
+		ali_mns.MessageSendRequest{MessageBody: body},
+		ali_mns.MessageSendRequest{MessageBody: body},
+		ali_mns.MessageSendRequest{MessageBody: body},
+	); err != nil {
+		t.Fatalf("Failed to batch send: %v", err)
+	}
+
+	// 每个请求的消息体总大小不超过 64KB
+	if n := client.requestCount("POST"); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestBatchSendChunkFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("chunk-queue", client, ali_mns.WithBatchParallelism(1))
+	client.injectError(500, "InternalError")
+
+	messages := []ali_mns.MessageSendRequest{}
+	for i := 0; i < 20; i++ {
+		messages = append(messages, ali_mns.MessageSendRequest{MessageBody: fmt.Sprintf("body-%d", i)})
+	}
+	resp, err := queue.BatchSendMessage(messages...)
+
+	// 第一个分块请求失败，其中的条目标记为失败，第二个分块正常发送
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 16 || batchErr.AllFailed() {
+		t.Fatalf("Expected first chunk to fail, got %v", err)
+	}
+	if resp.Messages[0].ErrorCode != ali_mns.BatchChunkFailedErrorCode || resp.Messages[16].MessageId == "" {
+		t.Errorf("Unexpected entries: %+v, %+v", resp.Messages[0], resp.Messages[16])
+	}
+	if n := len(client.messages("chunk-queue")); n != 4 {
+		t.Errorf("Expected 4 stored messages, got %d", n)
+	}
+}
+
+func TestBatchDeleteChunks(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("chunk-queue", client)
+	for i := 0; i < 20; i++ {
+		client.enqueue("chunk-queue", fmt.Sprintf("body-%d", i))
+	}
+
+	handles := []string{}
+	for i := 0; i < 2; i++ {
+		resp, _ := batchReceive(queue, 16)
+		for _, msg := range resp.Messages {
+			handles = append(handles, msg.ReceiptHandle)
+		}
+	}
+	handles = append(handles[:18], append([]string{"invalid-handle"}, handles[18:]...)...)
+
+	_, err := queue.BatchDeleteMessage(handles...)
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 18 {
+		t.Fatalf("Expected invalid handle at index 18 to fail, got %v", err)
+	}
+	if n := client.requestCount("DELETE"); n != 2 {
+		t.Errorf("Expected 2 delete requests, got %d", n)
+	}
+	if len(client.messages("chunk-queue")) != 0 {
+		t.Error("Expected all valid handles to be deleted")
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestBatchSendPartialFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("batch-queue", client)
+
+	resp, err := queue.BatchSendMessage(
+		ali_mns.MessageSendRequest{MessageBody: "ok"},
+		ali_mns.MessageSendRequest{MessageBody: "fail:MessageTooLarge"},
+		ali_mns.MessageSendRequest{MessageBody: "ok-2"},
+	)
+
+	// 兼容原有的错误码判断
+	if !ali_mns.ERR_MNS_BATCH_OP_FAIL.IsEqual(err) {
+		t.Fatalf("Expected ERR_MNS_BATCH_OP_FAIL, got %v", err)
+	}
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected BatchError, got %T", err)
+	}
+	if batchErr.AllFailed() || batchErr.Total != 3 {
+		t.Errorf("Expected partial failure of 3 entries, got %+v", batchErr)
+	}
+	if indexes := batchErr.FailedIndexes(); len(indexes) != 1 || indexes[0] != 1 {
+		t.Errorf("Expected failed index 1, got %v", indexes)
+	}
+	if batchErr.Failed[0].ErrorCode != "MessageTooLarge" || batchErr.Failed[0].Retryable() {
+		t.Errorf("Unexpected entry error: %+v", batchErr.Failed[0])
+	}
+	if resp.Messages[0].MessageId == "" || resp.Messages[2].MessageId == "" {
+		t.Error("Expected successful entries to keep their message ids")
+	}
+}
+
+func TestBatchSendAllFailed(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("batch-queue", client)
+
+	_, err := queue.BatchSendMessage(
+		ali_mns.MessageSendRequest{MessageBody: "fail:MessageTooLarge"},
+		ali_mns.MessageSendRequest{MessageBody: "fail:InvalidArgument"},
+	)
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) || !batchErr.AllFailed() {
+		t.Fatalf("Expected all entries to fail, got %v", err)
+	}
+}
+
+func TestBatchSendRetry(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("batch-queue", client, ali_mns.WithBatchRetry(2, time.Millisecond))
+
+	resp, err := queue.BatchSendMessage(
+		ali_mns.MessageSendRequest{MessageBody: "ok"},
+		ali_mns.MessageSendRequest{MessageBody: "fail-once:InternalError"},
+		ali_mns.MessageSendRequest{MessageBody: "fail:MessageTooLarge"},
+	)
+
+	// 只重试可重试的失败条目，不可重试的错误保留
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 2 {
+		t.Fatalf("Expected only the non-retryable entry to fail, got %v", err)
+	}
+	if resp.Messages[1].MessageId == "" || resp.Messages[1].ErrorCode != "" {
+		t.Errorf("Expected retried entry to succeed, got %+v", resp.Messages[1])
+	}
+	if n := len(client.messages("batch-queue")); n != 2 {
+		t.Errorf("Expected 2 stored messages, got %d", n)
+	}
+	if n := client.requestCount("POST"); n != 2 {
+		t.Errorf("Expected 1 retry request, got %d", n-1)
+	}
+}
+
+func TestBatchDeletePartialFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("batch-queue", client)
+	client.enqueue("batch-queue", "a")
+	client.enqueue("batch-queue", "b")
+
+	received, _ := batchReceive(queue, 16)
+	handles := []string{received.Messages[0].ReceiptHandle, "invalid-handle", received.Messages[1].ReceiptHandle}
+
+	_, err := queue.BatchDeleteMessage(handles...)
+	var batchErr *ali_mns.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected BatchError, got %v", err)
+	}
+	// 失败条目对应到输入中的下标
+	if len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 1 || batchErr.Failed[0].ReceiptHandle != "invalid-handle" {
+		t.Errorf("Unexpected failed entries: %+v", batchErr.Failed)
+	}
+	if len(client.messages("batch-queue")) != 0 {
+		t.Error("Expected valid handles to be deleted")
+	}
+}
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func countBlobs(t *testing.T, dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read blob dir: %v", err)
+	}
+	return len(entries)
+}
+
+func TestClaimCheckRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := ali_mns.NewLocalBlobStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("cc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewClaimCheckCodec(store, 1024, true)))
+
+	large := strings.Repeat("0123456789", 30000)
+	if _, err = queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: large}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if _, err = queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "small"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// 只有超过阈值的消息被转存
+	if !ali_mns.IsClaimCheckMessageBody(client.messages("cc-queue")[0].body) {
+		t.Error("Expected large body to be replaced by a reference")
+	}
+	if client.messages("cc-queue")[1].body != "small" {
+		t.Error("Expected small body to be sent inline")
+	}
+	if countBlobs(t, dir) != 1 {
+		t.Fatalf("Expected 1 blob, got %d", countBlobs(t, dir))
+	}
+
+	resp, err := batchReceive(queue, 16)
+	if err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+	if resp.Messages[0].MessageBody != large {
+		t.Error("Expected original body to be fetched from the blob store")
+	}
+
+	// 通过 ChangeMessageVisibility 更换句柄后删除，仍应清理 blob
+	changed, err := queue.ChangeMessageVisibility(resp.Messages[0].ReceiptHandle, 10)
+	if err != nil {
+		t.Fatalf("Failed to change visibility: %v", err)
+	}
+	if err = queue.DeleteMessage(changed.ReceiptHandle); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	if countBlobs(t, dir) != 0 {
+		t.Errorf("Expected blob to be deleted with the message, got %d blobs", countBlobs(t, dir))
+	}
+}
+
+func TestClaimCheckKeepBlob(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := ali_mns.NewLocalBlobStore(dir)
+
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("cc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewClaimCheckCodec(store, 16, false)))
+
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: strings.Repeat("x", 100)})
+	resp, err := batchReceive(queue, 16)
+	if err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+	if _, err = queue.BatchDeleteMessage(resp.Messages[0].ReceiptHandle); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if countBlobs(t, dir) != 1 {
+		t.Errorf("Expected blob to be kept, got %d blobs", countBlobs(t, dir))
+	}
+}
+
+func TestClaimCheckMissingBlob(t *testing.T) {
+	store, _ := ali_mns.NewLocalBlobStore(t.TempDir())
+
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("cc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewClaimCheckCodec(store, 16, true)))
+
+	// 指向不存在或非法 key 的引用
+	client.enqueue("cc-queue", "MNS1|claim-check|key=..%2Fetc%2Fpasswd|")
+
+	_, err := receiveOne(queue)
+	var decodeErr *ali_mns.MessageBodyDecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected MessageBodyDecodeError, got %v", err)
+	}
+}
+
+func TestClaimCheckMeasuresEncodedBody(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := ali_mns.NewLocalBlobStore(dir)
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("cc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewClaimCheckCodec(store, 0, true)),
+		ali_mns.WithMessageBodyEncoding(ali_mns.BASE64_ENCODING))
+
+	// 略小于阈值的消息体经 base64 编码后超过上限，仍应转存
+	large := strings.Repeat("a", ali_mns.DefaultClaimCheckThreshold-10)
+	small := strings.Repeat("b", 1000)
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: large})
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: small})
+
+	messages := client.messages("cc-queue")
+	for _, m := range messages {
+		if len(m.body) > ali_mns.DefaultClaimCheckThreshold {
+			t.Errorf("Expected sent body within %d bytes, got %d", ali_mns.DefaultClaimCheckThreshold, len(m.body))
+		}
+	}
+	if countBlobs(t, dir) != 1 {
+		t.Errorf("Expected only the large body to be offloaded, got %d blobs", countBlobs(t, dir))
+	}
+
+	resp, err := batchReceive(queue, 16)
+	if err != nil || len(resp.Messages) != 2 || resp.Messages[0].MessageBody != large || resp.Messages[1].MessageBody != small {
+		t.Errorf("Expected original bodies after receive, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func partitionOf(msg ali_mns.MessageReceiveResponse) string {
+	return strings.SplitN(msg.MessageBody, ":", 2)[0]
+}
+
+func TestConsumerPartitionKeepsOrder(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("partition-queue", client)
+	for i := 0; i < 5; i++ {
+		for _, key := range []string{"a", "b", "c"} {
+			client.enqueue("partition-queue", fmt.Sprintf("%s:%d", key, i))
+		}
+	}
+
+	var lock sync.Mutex
+	order := map[string][]string{}
+	var active, maxActive int32
+	consumer, err := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		// 先到的消息处理更慢，检验同一分区键仍按顺序处理
+		var key string
+		var seq int
+		fmt.Sscanf(strings.Replace(msg.MessageBody, ":", " ", 1), "%s %d", &key, &seq)
+		time.Sleep(time.Duration(10-seq) * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+
+		lock.Lock()
+		defer lock.Unlock()
+		order[partitionOf(msg)] = append(order[partitionOf(msg)], msg.MessageBody)
+		return nil
+	}, ali_mns.WithConsumerConcurrency(8), ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerPartitionKey(partitionOf))
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("partition-queue")) == 0 })
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, key := range []string{"a", "b", "c"} {
+		for i, body := range order[key] {
+			if expected := fmt.Sprintf("%s:%d", key, i); body != expected {
+				t.Errorf("Expected %s at position %d, got %v", expected, i, order[key])
+				break
+			}
+		}
+	}
+	// 不同分区键并行处理
+	if atomic.LoadInt32(&maxActive) < 2 {
+		t.Errorf("Expected different keys to be handled in parallel, got %d", maxActive)
+	}
+}
+
+func TestConsumerPartitionSkipsAfterFailure(t *testing.T) {
+	client := newMockMNSClient()
+	client.visibilityTimeout = 50 * time.Millisecond
+	queue, _ := ali_mns.NewMNSQueue("partition-queue", client)
+	for _, body := range []string{"a:0", "a:1", "b:0"} {
+		client.enqueue("partition-queue", body)
+	}
+
+	var lock sync.Mutex
+	var handled []string
+	var skipped int32
+	failed := false
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if msg.MessageBody == "a:0" && !failed {
+			failed = true
+			return errors.New("failed")
+		}
+		handled = append(handled, msg.MessageBody)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerPartitionKey(partitionOf),
+		ali_mns.WithConsumerErrorHandler(func(err error) {
+			var consumerErr *ali_mns.ConsumerError
+			if errors.As(err, &consumerErr) && consumerErr.Message.MessageBody == "a:1" {
+				atomic.AddInt32(&skipped, 1)
+			}
+		}))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("partition-queue")) == 0 })
+
+	// 前一条失败时后续同键消息被跳过，重新可见后按顺序处理
+	if atomic.LoadInt32(&skipped) != 1 {
+		t.Errorf("Expected a:1 to be skipped once, got %d", skipped)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	index := map[string]int{}
+	for i, body := range handled {
+		index[body] = i
+	}
+	if len(handled) != 3 || index["a:0"] > index["a:1"] {
+		t.Errorf("Expected a:0 before a:1, got %v", handled)
+	}
+}
+
+func TestConsumerPartitionLeaseWhileWaiting(t *testing.T) {
+	client := newMockMNSClient()
+	client.visibilityTimeout = time.Second
+	queue, _ := ali_mns.NewMNSQueue("partition-queue", client)
+	client.enqueue("partition-queue", "a:0")
+	client.enqueue("partition-queue", "a:1")
+
+	var lock sync.Mutex
+	handled := map[string]int{}
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		if msg.MessageBody == "a:0" {
+			time.Sleep(2500 * time.Millisecond)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		handled[msg.MessageBody]++
+		return nil
+	}, ali_mns.WithConsumerConcurrency(4), ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerPartitionKey(partitionOf),
+		ali_mns.WithConsumerLeaseExtension(ali_mns.WithLeaseVisibilityTimeout(1)))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("partition-queue")) == 0 })
+
+	// 等待轮次的时间超过可见时间，消息也不会被重复投递
+	lock.Lock()
+	defer lock.Unlock()
+	if handled["a:0"] != 1 || handled["a:1"] != 1 {
+		t.Errorf("Expected each message to be handled once, got %v", handled)
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+// runConsumer 运行消费者直到 done 返回 true 或超时
+func runConsumer(t *testing.T, consumer *ali_mns.Consumer, done func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- consumer.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("Timeout waiting for consumer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("Consumer returned error: %v", err)
+	}
+}
+
+func TestConsumerDeletesHandledMessages(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("consumer-queue", client)
+	for i := 0; i < 20; i++ {
+		client.enqueue("consumer-queue", fmt.Sprintf("body-%d", i))
+	}
+
+	var handled int32
+	var active, maxActive int32
+	consumer, err := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerConcurrency(3), ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("consumer-queue")) == 0 })
+
+	if atomic.LoadInt32(&handled) != 20 {
+		t.Errorf("Expected 20 handled messages, got %d", handled)
+	}
+	// 并发度不能超过配置
+	if atomic.LoadInt32(&maxActive) > 3 {
+		t.Errorf("Expected at most 3 concurrent handlers, got %d", maxActive)
+	}
+}
+
+func TestConsumerKeepsFailedMessages(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("consumer-queue", client)
+	client.enqueue("consumer-queue", "ok")
+	client.enqueue("consumer-queue", "fail")
+	client.enqueue("consumer-queue", "panic")
+
+	var lock sync.Mutex
+	var errs []error
+	var calls int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&calls, 1)
+		switch msg.MessageBody {
+		case "fail":
+			return errors.New("handler failed")
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerBatchDelete(true),
+		ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerErrorHandler(func(err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			errs = append(errs, err)
+		}))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("consumer-queue")) == 2 })
+
+	// 失败与 panic 的消息保留在队列中，等待可见性超时后重新消费
+	for _, m := range client.messages("consumer-queue") {
+		if m.body == "ok" {
+			t.Error("Expected handled message to be deleted")
+		}
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected 3 handler calls, got %d", calls)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 reported errors, got %v", errs)
+	}
+	for _, err := range errs {
+		var consumerErr *ali_mns.ConsumerError
+		if !errors.As(err, &consumerErr) || consumerErr.Message.ReceiptHandle == "" {
+			t.Errorf("Expected ConsumerError with message, got %v", err)
+		}
+	}
+	if client.requestCount("DELETE") != 1 {
+		t.Errorf("Expected a single batch delete request, got %d", client.requestCount("DELETE"))
+	}
+}
+
+func TestConsumerReceiveError(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("consumer-queue", client)
+	client.injectError(500, "InternalError")
+	client.enqueue("consumer-queue", "body")
+
+	var receiveErrs int32
+	var handled int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerErrorHandler(func(err error) {
+			atomic.AddInt32(&receiveErrs, 1)
+		}))
+
+	runConsumer(t, consumer, func() bool { return atomic.LoadInt32(&handled) == 1 })
+
+	// 空队列（MessageNotExist）不视为错误
+	if atomic.LoadInt32(&receiveErrs) != 1 {
+		t.Errorf("Expected exactly one reported receive error, got %d", receiveErrs)
+	}
+}
+
+func TestConsumerInvalidOptions(t *testing.T) {
+	queue, _ := ali_mns.NewMNSQueue("consumer-queue", newMockMNSClient())
+	handler := func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error { return nil }
+
+	invalid := []ali_mns.ConsumerOption{
+		ali_mns.WithConsumerConcurrency(0),
+		ali_mns.WithConsumerBatchSize(17),
+		ali_mns.WithConsumerWaitSeconds(31),
+		ali_mns.WithConsumerWaitSeconds(1<<32 + 5),
+	}
+	for i, option := range invalid {
+		if _, err := ali_mns.NewConsumer(queue, handler, option); err == nil {
+			t.Errorf("Case %d: expected invalid option error", i)
+		}
+	}
+	if _, err := ali_mns.NewConsumer(queue, nil); err == nil {
+		t.Error("Expected nil handler to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestConsumerDeadLetter(t *testing.T) {
+	client := newMockMNSClient()
+	// 可见性超时为 0，失败的消息立即重新可见
+	client.visibilityTimeout = 0
+	queue, _ := ali_mns.NewMNSQueue("source-queue", client)
+	dlq, _ := ali_mns.NewMNSQueue("dead-queue", client)
+	client.enqueue("source-queue", "poison")
+
+	policy, err := ali_mns.NewDeadLetterPolicy(dlq, 3)
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+
+	var calls int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("cannot parse order")
+	}, ali_mns.WithConsumerConcurrency(1), ali_mns.WithConsumerBatchSize(1), ali_mns.WithConsumerWaitSeconds(0),
+		ali_mns.WithConsumerErrorDelay(10*time.Millisecond), ali_mns.WithConsumerDeadLetterPolicy(policy))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("dead-queue")) == 1 })
+
+	if len(client.messages("source-queue")) != 0 {
+		t.Error("Expected poison message to be deleted from source queue")
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected 3 handler calls, got %d", calls)
+	}
+
+	dead := client.messages("dead-queue")[0]
+	if dead.body != "poison" || dead.priority != 8 {
+		t.Errorf("Unexpected dead letter: %+v", dead)
+	}
+	if id, _ := dead.userProps.Get(ali_mns.DeadLetterOriginalMessageIdProperty); id == "" {
+		t.Error("Expected original message id property")
+	}
+	if reason, _ := dead.userProps.Get(ali_mns.DeadLetterFailureReasonProperty); reason != "cannot parse order" {
+		t.Errorf("Expected failure reason, got %q", reason)
+	}
+}
+
+func TestConsumerDeadLetterExceeded(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("source-queue", client)
+	dlq, _ := ali_mns.NewMNSQueue("dead-queue", client)
+	client.enqueue("source-queue", "crashed")
+
+	// 模拟之前的消费进程在处理中崩溃，消息已被多次接收
+	msg, _ := receiveOne(queue)
+	queue.ChangeMessageVisibility(msg.ReceiptHandle, 1)
+	time.Sleep(1100 * time.Millisecond)
+
+	policy, _ := ali_mns.NewDeadLetterPolicy(dlq, 1)
+	var calls int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerDeadLetterPolicy(policy))
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("dead-queue")) == 1 })
+
+	// 超过最大接收次数的消息不再交给处理函数
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("Expected handler not to be called, got %d", calls)
+	}
+}
+
+func TestRedriveDeadLetters(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("source-queue", client)
+	other, _ := ali_mns.NewMNSQueue("other-queue", client)
+	dlq, _ := ali_mns.NewMNSQueue("dead-queue", client)
+	policy, _ := ali_mns.NewDeadLetterPolicy(dlq, 1)
+
+	msg := ali_mns.MessageSendRequest{MessageBody: "order", Priority: 3}
+	msg.UserProperties.Set("tenant", "t-1")
+	queue.SendMessage(msg)
+	other.SendMessage(ali_mns.MessageSendRequest{MessageBody: "other", Priority: 8})
+
+	for _, q := range []ali_mns.AliMNSQueue{queue, other} {
+		received, _ := receiveOne(q)
+		if err := policy.DeadLetter(q, received, "failed"); err != nil {
+			t.Fatalf("Failed to dead-letter message: %v", err)
+		}
+	}
+
+	moved, err := ali_mns.RedriveDeadLetters(context.Background(), dlq, queue, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to redrive: %v", err)
+	}
+	if moved != 1 {
+		t.Fatalf("Expected 1 redriven message, got %d", moved)
+	}
+
+	restored := client.messages("source-queue")
+	if len(restored) != 1 || restored[0].body != "order" || restored[0].priority != 3 {
+		t.Fatalf("Unexpected redriven messages: %+v", restored)
+	}
+	// 死信属性被移除，原有属性保留
+	if len(restored[0].userProps) != 1 || restored[0].userProps[0].Name != "tenant" {
+		t.Errorf("Unexpected properties after redrive: %+v", restored[0].userProps)
+	}
+	if len(client.messages("dead-queue")) != 1 {
+		t.Error("Expected message from other queue to stay in dead-letter queue")
+	}
+}
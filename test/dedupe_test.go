@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestConsumerDedupeDropsDuplicates(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("dedupe-queue", client)
+	for _, body := range []string{"order-1", "order-1", "order-2", "order-1"} {
+		client.enqueue("dedupe-queue", body)
+	}
+
+	// 以消息体中的业务键去重
+	dedupe := ali_mns.NewDeduplicator(nil, time.Minute, ali_mns.WithDedupeKey(func(msg ali_mns.MessageReceiveResponse) (string, error) {
+		return msg.MessageBody, nil
+	}))
+	var handled int32
+	consumer, err := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerConcurrency(1), ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerMiddleware(dedupe.Middleware()))
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("dedupe-queue")) == 0 })
+
+	// 重复消息被跳过并删除
+	if n := atomic.LoadInt32(&handled); n != 2 {
+		t.Errorf("Expected 2 handled messages, got %d", n)
+	}
+	if n := dedupe.Dropped(); n != 2 {
+		t.Errorf("Expected 2 dropped duplicates, got %d", n)
+	}
+}
+
+func TestDeduplicatorKeepsFailedKeys(t *testing.T) {
+	dedupe := ali_mns.NewDeduplicator(ali_mns.NewMemoryDedupeStore(), time.Minute)
+	calls := 0
+	handler := dedupe.Wrap(func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		if calls++; calls == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	msg := ali_mns.MessageReceiveResponse{}
+	msg.MessageId = "id-1"
+
+	// 处理失败的消息不记录，重新投递时再次处理
+	if err := handler(context.Background(), msg); err == nil {
+		t.Fatal("Expected handler error")
+	}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if calls != 2 || dedupe.Dropped() != 1 {
+		t.Errorf("Expected 2 calls and 1 dropped, got %d and %d", calls, dedupe.Dropped())
+	}
+}
+
+func TestDeduplicatorInflightDuplicate(t *testing.T) {
+	dedupe := ali_mns.NewDeduplicator(nil, time.Minute)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := dedupe.Wrap(func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		close(started)
+		<-release
+		return nil
+	})
+	msg := ali_mns.MessageReceiveResponse{}
+	msg.MessageId = "id-1"
+
+	done := make(chan error, 1)
+	go func() { done <- handler(context.Background(), msg) }()
+	<-started
+
+	// 同一消息正在处理时，重复投递返回 ErrDuplicateInFlight 以便稍后重新可见
+	if err := handler(context.Background(), msg); !errors.Is(err, ali_mns.ErrDuplicateInFlight) {
+		t.Errorf("Expected ErrDuplicateInFlight, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestConsumerInflightDuplicateNotCounted(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("dedupe-queue", client)
+	client.enqueue("dedupe-queue", "order-1")
+	client.enqueue("dedupe-queue", "order-1")
+	backoff, _ := ali_mns.NewRedeliveryBackoff(10*time.Second, time.Hour)
+
+	dedupe := ali_mns.NewDeduplicator(nil, time.Minute, ali_mns.WithDedupeKey(func(msg ali_mns.MessageReceiveResponse) (string, error) {
+		return msg.MessageBody, nil
+	}))
+	release := make(chan struct{})
+	var handled, reported int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		<-release
+		return nil
+	}, ali_mns.WithConsumerConcurrency(2), ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerRedeliveryBackoff(backoff),
+		ali_mns.WithConsumerErrorHandler(func(err error) { atomic.AddInt32(&reported, 1) }),
+		ali_mns.WithConsumerMiddleware(dedupe.Middleware()))
+
+	// 第一份正在处理时到达的副本既不报告错误也不触发退避重投
+	runConsumer(t, consumer, func() bool {
+		if atomic.LoadInt32(&handled) == 1 && client.requestCount("GET") >= 3 {
+			close(release)
+			return true
+		}
+		return false
+	})
+	if n := client.requestCount("PUT"); n != 0 {
+		t.Errorf("Expected no redelivery for in-flight duplicate, got %d", n)
+	}
+	if n := atomic.LoadInt32(&reported); n != 0 {
+		t.Errorf("Expected no reported errors, got %d", n)
+	}
+}
+
+// failingMarkStore 模拟记录去重键失败的存储
+type failingMarkStore struct{}
+
+func (failingMarkStore) Seen(key string) (bool, error) { return false, nil }
+
+func (failingMarkStore) Mark(key string, ttl time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func TestConsumerDedupeMarkFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("dedupe-queue", client)
+	client.enqueue("dedupe-queue", "order-1")
+	backoff, _ := ali_mns.NewRedeliveryBackoff(10*time.Second, time.Hour)
+
+	var handled int32
+	reported := make(chan error, 10)
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerRedeliveryBackoff(backoff),
+		ali_mns.WithConsumerErrorHandler(func(err error) { reported <- err }),
+		ali_mns.WithConsumerMiddleware(ali_mns.NewDeduplicator(failingMarkStore{}, time.Minute).Middleware()))
+
+	// 记录去重键失败时仍删除已处理的消息，并报告错误
+	runConsumer(t, consumer, func() bool { return len(client.messages("dedupe-queue")) == 0 })
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("Expected message to be handled once, got %d", n)
+	}
+	if n := client.requestCount("PUT"); n != 0 {
+		t.Errorf("Expected no redelivery, got %d", n)
+	}
+	select {
+	case err := <-reported:
+		var markErr *ali_mns.DedupeMarkError
+		if !errors.As(err, &markErr) || markErr.Key == "" {
+			t.Errorf("Expected DedupeMarkError, got %v", err)
+		}
+	default:
+		t.Error("Expected mark failure to be reported")
+	}
+}
+
+func TestMemoryDedupeStoreExpires(t *testing.T) {
+	store := ali_mns.NewMemoryDedupeStore()
+	store.Mark("a", 50*time.Millisecond)
+	if seen, _ := store.Seen("a"); !seen {
+		t.Error("Expected a to be seen")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if seen, _ := store.Seen("a"); seen {
+		t.Error("Expected a to expire")
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func newTestKeyProvider(t *testing.T, current string, keyIds ...string) *ali_mns.StaticKeyProvider {
+	keys := map[string][]byte{}
+	for _, keyId := range keyIds {
+		keys[keyId] = bytes.Repeat([]byte(keyId[:1]), 32)
+	}
+	provider, err := ali_mns.NewStaticKeyProvider(current, keys)
+	if err != nil {
+		t.Fatalf("Failed to create key provider: %v", err)
+	}
+	return provider
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("enc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k1", "k1"))))
+
+	body := `{"name":"Zhang San","phone":"13800000000"}`
+	if _, err := queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: body}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	stored := client.messages("enc-queue")[0].body
+	if !ali_mns.IsEncryptedMessageBody(stored) || strings.Contains(stored, "Zhang San") {
+		t.Fatalf("Expected encrypted body on the wire, got %q", stored)
+	}
+
+	resp, err := receiveOne(queue)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if resp.MessageBody != body {
+		t.Errorf("Expected decrypted body, got %q", resp.MessageBody)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	client := newMockMNSClient()
+	oldQueue, _ := ali_mns.NewMNSQueueWithOptions("enc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k1", "k1"))))
+	oldQueue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "old key"})
+
+	// 轮转后，新密钥用于加密，旧密钥仍可解密存量消息
+	newQueue, _ := ali_mns.NewMNSQueueWithOptions("enc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k2", "k1", "k2"))))
+	newQueue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "new key"})
+
+	resp, err := batchReceive(newQueue, 16)
+	if err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+	if resp.Messages[0].MessageBody != "old key" || resp.Messages[1].MessageBody != "new key" {
+		t.Errorf("Unexpected bodies: %q, %q", resp.Messages[0].MessageBody, resp.Messages[1].MessageBody)
+	}
+}
+
+func TestEncryptionUnknownKey(t *testing.T) {
+	client := newMockMNSClient()
+	producer, _ := ali_mns.NewMNSQueueWithOptions("enc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k1", "k1"))))
+	producer.SendMessage(ali_mns.MessageSendRequest{MessageBody: "secret"})
+	producer.SendMessage(ali_mns.MessageSendRequest{MessageBody: "secret"})
+
+	consumer, _ := ali_mns.NewMNSQueueWithOptions("enc-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k2", "k2"))))
+
+	_, err := batchReceive(consumer, 16)
+	var decryptErr *ali_mns.DecryptionError
+	if !errors.As(err, &decryptErr) {
+		t.Fatalf("Expected DecryptionError, got %v", err)
+	}
+	if decryptErr.KeyId != "k1" {
+		t.Errorf("Expected key id k1, got %s", decryptErr.KeyId)
+	}
+
+	var decodeErr *ali_mns.MessageBodyDecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Errors) != 2 {
+		t.Errorf("Expected both messages to fail, got %v", err)
+	}
+}
+
+func TestEncryptionTamperedBody(t *testing.T) {
+	codec := ali_mns.NewEncryptionCodec(newTestKeyProvider(t, "k1", "k1"))
+	encoded, err := codec.EncodeBody("payload")
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// 篡改密钥 ID 会导致认证失败
+	tampered := strings.Replace(encoded, "kid=k1", "kid=k2", 1)
+	if _, err = codec.DecodeBody(tampered); err == nil {
+		t.Error("Expected error for tampered body")
+	}
+
+	if _, err = ali_mns.NewStaticKeyProvider("k1", map[string][]byte{"k1": []byte("short")}); err == nil {
+		t.Error("Expected error for invalid key length")
+	}
+}
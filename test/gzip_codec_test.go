@@ -0,0 +1,154 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestGzipBodyCodecRoundTrip(t *testing.T) {
+	client := newMockMNSClient()
+	queue, err := ali_mns.NewMNSQueueWithOptions("gzip-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(128)))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	body := strings.Repeat(`{"customer":"c-1","amount":100}`, 100)
+	if _, err = queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: body}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// 服务端看到的应是压缩后的消息体
+	stored := client.messages("gzip-queue")[0].body
+	if !ali_mns.IsGzipMessageBody(stored) {
+		t.Fatalf("Expected compressed body on the wire, got %q", stored[:20])
+	}
+	if len(stored) >= len(body) {
+		t.Errorf("Expected compressed body to be smaller, got %d >= %d", len(stored), len(body))
+	}
+
+	resp, err := receiveOne(queue)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if resp.MessageBody != body {
+		t.Errorf("Expected decompressed body, got %q", resp.MessageBody)
+	}
+}
+
+func TestGzipBodyCodecBelowThreshold(t *testing.T) {
+	codec := ali_mns.NewGzipBodyCodec(1024)
+
+	encoded, err := codec.EncodeBody("small body")
+	if err != nil {
+		t.Fatalf("Failed to encode body: %v", err)
+	}
+	if encoded != "small body" {
+		t.Errorf("Expected body below threshold to be unchanged, got %q", encoded)
+	}
+
+	// 未压缩的消息体解码时原样返回
+	decoded, err := codec.DecodeBody("plain body")
+	if err != nil || decoded != "plain body" {
+		t.Errorf("Expected plain body to pass through, got %q, %v", decoded, err)
+	}
+}
+
+func TestGzipBodyCodecBatch(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("gzip-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(64)))
+
+	bodies := []string{strings.Repeat("a", 1000), "short", strings.Repeat("b", 2000)}
+	messages := []ali_mns.MessageSendRequest{}
+	for _, body := range bodies {
+		messages = append(messages, ali_mns.MessageSendRequest{MessageBody: body})
+	}
+	if _, err := queue.BatchSendMessage(messages...); err != nil {
+		t.Fatalf("Failed to batch send: %v", err)
+	}
+
+	resp, err := batchReceive(queue, 16)
+	if err != nil {
+		t.Fatalf("Failed to batch receive: %v", err)
+	}
+	if len(resp.Messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(resp.Messages))
+	}
+	for i, msg := range resp.Messages {
+		if msg.MessageBody != bodies[i] {
+			t.Errorf("Message %d: body mismatch", i)
+		}
+	}
+}
+
+func TestGzipBodyDecodeError(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("gzip-queue", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(64)))
+
+	// 带有压缩标记但内容损坏的消息
+	client.enqueue("gzip-queue", "MNS1|gzip||not-base64!")
+
+	_, err := receiveOne(queue)
+	var decodeErr *ali_mns.MessageBodyDecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected MessageBodyDecodeError, got %v", err)
+	}
+	if decodeErr.Messages[0].ReceiptHandle == "" {
+		t.Error("Expected raw message with receipt handle in decode error")
+	}
+	if !ali_mns.ERR_MNS_DECODE_MESSAGE_BODY_FAILED.IsEqual(decodeErr.Errors[0]) {
+		t.Errorf("Expected ERR_MNS_DECODE_MESSAGE_BODY_FAILED, got %v", decodeErr.Errors[0])
+	}
+}
+
+func TestGzipTopicPublish(t *testing.T) {
+	client := newMockMNSClient()
+	topic, err := ali_mns.NewMNSTopicWithOptions("gzip-topic", client,
+		ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(64)))
+	if err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+
+	body := strings.Repeat("x", 4096)
+	if _, err = topic.PublishMessage(ali_mns.MessagePublishRequest{MessageBody: body}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	published := client.published["gzip-topic"][0].MessageBody
+	if kind, ok := ali_mns.MessageBodyEnvelopeKind(published); !ok || kind != ali_mns.GzipEnvelopeKind {
+		t.Errorf("Expected gzip envelope, got %q", published)
+	}
+	decoded, err := ali_mns.NewGzipBodyCodec(64).DecodeBody(published)
+	if err != nil || decoded != body {
+		t.Errorf("Failed to decode published body: %v", err)
+	}
+}
+
+func TestGzipBodyCodecMaxDecodedSize(t *testing.T) {
+	codec := ali_mns.NewGzipBodyCodec(64)
+	bomb, err := codec.EncodeBody(strings.Repeat("0", ali_mns.DefaultGzipMaxDecodedSize+1))
+	if err != nil || !ali_mns.IsGzipMessageBody(bomb) {
+		t.Fatalf("Failed to encode body: %v", err)
+	}
+
+	// 解压后超过上限的消息体解码失败
+	if _, err = codec.DecodeBody(bomb); !ali_mns.ERR_MNS_DECODE_MESSAGE_BODY_FAILED.IsEqual(err) {
+		t.Errorf("Expected decode error for oversized body, got %v", err)
+	}
+
+	codec.SetMaxDecodedSize(1024)
+	body := strings.Repeat("1", 1024)
+	encoded, _ := codec.EncodeBody(body)
+	if decoded, err := codec.DecodeBody(encoded); err != nil || decoded != body {
+		t.Errorf("Expected body at the limit to decode, got %v", err)
+	}
+	encoded, _ = codec.EncodeBody(body + "1")
+	if _, err = codec.DecodeBody(encoded); err == nil {
+		t.Error("Expected error for body above the configured limit")
+	}
+}
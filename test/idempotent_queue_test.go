@@ -0,0 +1,166 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestIdempotentQueueDeduplicates(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("idempotent-queue", client)
+	idempotent, err := ali_mns.NewIdempotentQueue(queue, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create idempotent queue: %v", err)
+	}
+
+	first, err := idempotent.SendMessage("order-1", ali_mns.MessageSendRequest{MessageBody: "body"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	// 相同幂等键的重试返回第一次的结果且不重复发送
+	second, err := idempotent.SendMessage("order-1", ali_mns.MessageSendRequest{MessageBody: "body"})
+	if err != nil || second.MessageId != first.MessageId {
+		t.Errorf("Expected stored response %s, got %s, %v", first.MessageId, second.MessageId, err)
+	}
+	if _, err = idempotent.SendMessage("order-2", ali_mns.MessageSendRequest{MessageBody: "body"}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if n := len(client.messages("idempotent-queue")); n != 2 {
+		t.Errorf("Expected 2 stored messages, got %d", n)
+	}
+}
+
+func TestIdempotentQueueConcurrentRetries(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("idempotent-queue", client)
+	idempotent, _ := ali_mns.NewIdempotentQueue(queue, ali_mns.NewMemoryIdempotencyStore(10), time.Minute)
+
+	var wg sync.WaitGroup
+	ids := make([]string, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, _ := idempotent.SendMessage("order-1", ali_mns.MessageSendRequest{MessageBody: "body"})
+			ids[i] = resp.MessageId
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(client.messages("idempotent-queue")); n != 1 {
+		t.Errorf("Expected a single message, got %d", n)
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Errorf("Expected all retries to return %s, got %s", ids[0], id)
+		}
+	}
+}
+
+func TestIdempotentQueueSendFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("idempotent-queue", client)
+	idempotent, _ := ali_mns.NewIdempotentQueue(queue, nil, time.Minute)
+
+	// 发送失败时不记录幂等键，重试会真正发送
+	client.injectError(500, "InternalError")
+	if _, err := idempotent.SendMessage("order-1", ali_mns.MessageSendRequest{MessageBody: "body"}); err == nil {
+		t.Fatal("Expected injected error")
+	}
+	if resp, err := idempotent.SendMessage("order-1", ali_mns.MessageSendRequest{MessageBody: "body"}); err != nil || resp.MessageId == "" {
+		t.Errorf("Expected retry to send, got %v", err)
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := ali_mns.NewMemoryIdempotencyStore(2)
+	expire := time.Now().Add(time.Minute)
+	store.Put("a", ali_mns.MessageSendResponse{MessageId: "1"}, expire)
+	store.Put("b", ali_mns.MessageSendResponse{MessageId: "2"}, expire)
+	store.Get("a")
+	store.Put("c", ali_mns.MessageSendResponse{MessageId: "3"}, expire)
+
+	// 容量满时淘汰最久未使用的记录
+	if _, ok, _ := store.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if resp, ok, _ := store.Get("a"); !ok || resp.MessageId != "1" {
+		t.Error("Expected a to be kept")
+	}
+
+	store.Put("d", ali_mns.MessageSendResponse{MessageId: "4"}, time.Now().Add(-time.Second))
+	if _, ok, _ := store.Get("d"); ok {
+		t.Error("Expected expired record to be ignored")
+	}
+}
+
+func TestFileIdempotencyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+	store, err := ali_mns.NewFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	store.Put("live", ali_mns.MessageSendResponse{MessageId: "1"}, time.Now().Add(time.Hour))
+	store.Put("expired", ali_mns.MessageSendResponse{MessageId: "2"}, time.Now().Add(50*time.Millisecond))
+	store.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 重新打开后保留未过期的记录
+	reopened, err := ali_mns.NewFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if resp, ok, _ := reopened.Get("live"); !ok || resp.MessageId != "1" {
+		t.Errorf("Expected live record after reopen, got %+v, %v", resp, ok)
+	}
+	if _, ok, _ := reopened.Get("expired"); ok {
+		t.Error("Expected expired record to be dropped")
+	}
+}
+
+func TestFileIdempotencyStoreCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+	store, err := ali_mns.NewFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	store.Put("live", ali_mns.MessageSendResponse{MessageId: "live"}, time.Now().Add(time.Hour))
+	// 运行期间过期记录被清理，失效行多于有效行时压缩文件
+	for i := 0; i < 600; i++ {
+		store.Put(fmt.Sprintf("expired-%d", i), ali_mns.MessageSendResponse{}, time.Now().Add(-time.Second))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read store file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines >= 600 {
+		t.Errorf("Expected the file to be compacted, got %d lines", lines)
+	}
+	if resp, ok, _ := store.Get("live"); !ok || resp.MessageId != "live" {
+		t.Error("Expected live record to survive compaction")
+	}
+	if _, ok, _ := store.Get("expired-0"); ok {
+		t.Error("Expected expired record to be dropped")
+	}
+
+	// 压缩后仍可继续写入并在重新打开后读到
+	store.Put("after", ali_mns.MessageSendResponse{MessageId: "after"}, time.Now().Add(time.Hour))
+	store.Close()
+	reopened, _ := ali_mns.NewFileIdempotencyStore(path)
+	defer reopened.Close()
+	if _, ok, _ := reopened.Get("after"); !ok {
+		t.Error("Expected record written after compaction to be kept")
+	}
+}
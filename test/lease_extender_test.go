@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestLeaseExtenderKeepsMessageInvisible(t *testing.T) {
+	client := newMockMNSClient()
+	client.visibilityTimeout = time.Second
+	queue, _ := ali_mns.NewMNSQueue("lease-queue", client)
+	client.enqueue("lease-queue", "slow")
+
+	extender, err := ali_mns.NewLeaseExtender(queue, ali_mns.WithLeaseVisibilityTimeout(1))
+	if err != nil {
+		t.Fatalf("Failed to create lease extender: %v", err)
+	}
+
+	msg, err := receiveOne(queue)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	lease := extender.Track(msg)
+
+	// 超过可见性超时时间后消息仍不可见
+	time.Sleep(2 * time.Second)
+	if _, err = receiveOne(queue); !ali_mns.ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+		t.Fatalf("Expected message to stay invisible, got %v", err)
+	}
+
+	handle := lease.Stop()
+	if handle == msg.ReceiptHandle {
+		t.Error("Expected receipt handle to be renewed")
+	}
+	if lease.Expired() {
+		t.Error("Expected lease not to be expired")
+	}
+	if err = queue.DeleteMessage(handle); err != nil {
+		t.Errorf("Failed to delete with renewed handle: %v", err)
+	}
+}
+
+func TestLeaseExtenderMaxAge(t *testing.T) {
+	client := newMockMNSClient()
+	client.visibilityTimeout = time.Second
+	queue, _ := ali_mns.NewMNSQueue("lease-queue", client)
+	client.enqueue("lease-queue", "stuck")
+
+	extender, _ := ali_mns.NewLeaseExtender(queue,
+		ali_mns.WithLeaseVisibilityTimeout(1), ali_mns.WithLeaseMaxAge(1500*time.Millisecond))
+
+	msg, _ := receiveOne(queue)
+	lease := extender.Track(msg)
+
+	// 达到最大租期后不再续期，消息重新可见
+	deadline := time.Now().Add(5 * time.Second)
+	for !lease.Expired() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for lease to expire")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	lease.Stop()
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := receiveOne(queue); err != nil {
+		t.Errorf("Expected message to reappear after max age, got %v", err)
+	}
+}
+
+func TestConsumerLeaseExtension(t *testing.T) {
+	client := newMockMNSClient()
+	client.visibilityTimeout = time.Second
+	queue, _ := ali_mns.NewMNSQueue("lease-queue", client)
+	client.enqueue("lease-queue", "slow")
+
+	var calls int32
+	consumer, err := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(2500 * time.Millisecond)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerLeaseExtension(ali_mns.WithLeaseVisibilityTimeout(1)))
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	runConsumer(t, consumer, func() bool { return len(client.messages("lease-queue")) == 0 })
+
+	// 续期成功时消息只会被处理一次，并使用新的句柄删除
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected message to be handled once, got %d", calls)
+	}
+}
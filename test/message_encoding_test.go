@@ -0,0 +1,122 @@
+package test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestBase64EncodingRoundTrip(t *testing.T) {
+	client := newMockMNSClient()
+	queue, err := ali_mns.NewMNSQueueWithOptions("b64-queue", client,
+		ali_mns.WithMessageBodyEncoding(ali_mns.BASE64_ENCODING))
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	body := "hello <\"aliyun-mns-go-sdk\">"
+	if _, err = queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: body}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	stored := client.messages("b64-queue")[0].body
+	if stored != base64.StdEncoding.EncodeToString([]byte(body)) {
+		t.Errorf("Expected base64 body on the wire, got %q", stored)
+	}
+
+	resp, err := receiveOne(queue)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if resp.MessageBody != body {
+		t.Errorf("Expected decoded body %q, got %q", body, resp.MessageBody)
+	}
+}
+
+func TestBase64EncodingFromOtherSDK(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("b64-queue", client,
+		ali_mns.WithMessageBodyEncoding(ali_mns.AUTO_ENCODING))
+
+	// 模拟 Java SDK 生产的 base64 消息和 Go SDK 生产的原始消息混合
+	client.enqueue("b64-queue", base64.StdEncoding.EncodeToString([]byte("from java")))
+	client.enqueue("b64-queue", "from go")
+
+	resp, err := batchReceive(queue, 16)
+	if err != nil {
+		t.Fatalf("Failed to batch receive: %v", err)
+	}
+	if resp.Messages[0].MessageBody != "from java" || resp.Messages[1].MessageBody != "from go" {
+		t.Errorf("Unexpected bodies: %q, %q", resp.Messages[0].MessageBody, resp.Messages[1].MessageBody)
+	}
+
+	// AUTO 模式发送时不编码
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "raw"})
+	messages := client.messages("b64-queue")
+	if messages[len(messages)-1].body != "raw" {
+		t.Errorf("Expected AUTO encoding to send raw body, got %q", messages[len(messages)-1].body)
+	}
+}
+
+func TestBase64EncodingWithCodec(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueueWithOptions("b64-queue", client,
+		ali_mns.WithMessageBodyEncoding(ali_mns.BASE64_ENCODING),
+		ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(64)))
+
+	body := strings.Repeat("compress me ", 100)
+	queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: body})
+
+	// 编码在压缩之后进行，线上是 base64 包裹的压缩包
+	stored := client.messages("b64-queue")[0].body
+	decoded, err := ali_mns.DecodeMessageBody(stored, ali_mns.BASE64_ENCODING)
+	if err != nil || !ali_mns.IsGzipMessageBody(decoded) {
+		t.Fatalf("Expected base64 wrapped gzip body, got %q, %v", decoded, err)
+	}
+
+	resp, err := receiveOne(queue)
+	if err != nil || resp.MessageBody != body {
+		t.Errorf("Failed to receive original body: %v", err)
+	}
+}
+
+func TestDetectMessageBodyEncoding(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected ali_mns.MessageBodyEncoding
+	}{
+		{base64.StdEncoding.EncodeToString([]byte("hello world")), ali_mns.BASE64_ENCODING},
+		{"hello world", ali_mns.RAW_ENCODING},
+		{`{"id":1}`, ali_mns.RAW_ENCODING},
+		{"", ali_mns.RAW_ENCODING},
+		{"test", ali_mns.RAW_ENCODING},
+	}
+
+	for _, tc := range testCases {
+		if got := ali_mns.DetectMessageBodyEncoding(tc.body); got != tc.expected {
+			t.Errorf("DetectMessageBodyEncoding(%q) = %s, expected %s", tc.body, got, tc.expected)
+		}
+	}
+
+	if _, err := ali_mns.DecodeMessageBody("not base64!", ali_mns.BASE64_ENCODING); err == nil {
+		t.Error("Expected error when decoding invalid base64 body")
+	}
+}
+
+func TestAutoEncodingIsLossy(t *testing.T) {
+	client := newMockMNSClient()
+	auto, _ := ali_mns.NewMNSQueueWithOptions("b64-queue", client,
+		ali_mns.WithMessageBodyEncoding(ali_mns.AUTO_ENCODING))
+	raw, _ := ali_mns.NewMNSQueue("b64-queue", client)
+
+	// 原始消息体恰好是合法的 base64 时，AUTO 模式会误解码，默认的 RAW 模式保持原样
+	client.enqueue("b64-queue", "Zm9v")
+	if msg, err := raw.(ali_mns.AliMNSReceiver).Peek(); err != nil || msg.MessageBody != "Zm9v" {
+		t.Errorf("Expected RAW encoding to keep the body, got %+v, %v", msg, err)
+	}
+	if msg, err := auto.(ali_mns.AliMNSReceiver).Peek(); err != nil || msg.MessageBody != "foo" {
+		t.Errorf("Expected AUTO encoding to decode the body, got %+v, %v", msg, err)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestMessageUserProperties(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("props-queue", client)
+
+	msg := ali_mns.MessageSendRequest{MessageBody: "body", Priority: 8}
+	msg.UserProperties.Set("tenant", "t-1")
+	msg.UserProperties.SetTyped("retry", "3", ali_mns.NUMBER_PROPERTY)
+	if _, err := queue.SendMessage(msg); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	resp, err := receiveOne(queue)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if v, ok := resp.UserProperties.Get("tenant"); !ok || v != "t-1" {
+		t.Errorf("Expected tenant property t-1, got %q", v)
+	}
+	if len(resp.UserProperties) != 2 || resp.UserProperties[1].Type != ali_mns.NUMBER_PROPERTY {
+		t.Errorf("Unexpected properties: %+v", resp.UserProperties)
+	}
+}
+
+func TestBatchMessageUserProperties(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("props-queue", client)
+
+	first := ali_mns.MessageSendRequest{MessageBody: "first"}
+	first.UserProperties.Set("route", "a")
+	second := ali_mns.MessageSendRequest{MessageBody: "second"}
+	if _, err := queue.BatchSendMessage(first, second); err != nil {
+		t.Fatalf("Failed to batch send: %v", err)
+	}
+
+	resp, err := batchReceive(queue, 16)
+	if err != nil {
+		t.Fatalf("Failed to batch receive: %v", err)
+	}
+	if v, _ := resp.Messages[0].UserProperties.Get("route"); v != "a" {
+		t.Errorf("Expected route property on first message, got %q", v)
+	}
+	if len(resp.Messages[1].UserProperties) != 0 {
+		t.Errorf("Expected no properties on second message, got %+v", resp.Messages[1].UserProperties)
+	}
+}
+
+func TestPublishUserProperties(t *testing.T) {
+	client := newMockMNSClient()
+	topic, _ := ali_mns.NewMNSTopic("props-topic", client)
+
+	msg := ali_mns.MessagePublishRequest{MessageBody: "body", MessageTag: "tag"}
+	msg.UserProperties.SetTyped("vip", "true", ali_mns.BOOLEAN_PROPERTY)
+	if _, err := topic.PublishMessage(msg); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	if v, _ := client.published["props-topic"][0].UserProperties.Get("vip"); v != "true" {
+		t.Errorf("Expected vip property to be published, got %q", v)
+	}
+}
+
+func TestInvalidUserProperties(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("props-queue", client)
+
+	testCases := []ali_mns.MessageProperties{
+		{{Name: "", Value: "v", Type: ali_mns.STRING_PROPERTY}},
+		{{Name: "a", Value: "v", Type: ali_mns.STRING_PROPERTY}, {Name: "a", Value: "w", Type: ali_mns.STRING_PROPERTY}},
+		{{Name: "n", Value: "abc", Type: ali_mns.NUMBER_PROPERTY}},
+		{{Name: "u", Value: "v", Type: "UNKNOWN"}},
+	}
+
+	for i, properties := range testCases {
+		_, err := queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body", UserProperties: properties})
+		if !ali_mns.ERR_MNS_INVALID_MESSAGE_PROPERTY.IsEqual(err) {
+			t.Errorf("Case %d: expected ERR_MNS_INVALID_MESSAGE_PROPERTY, got %v", i, err)
+		}
+	}
+	if client.requestCount("POST") != 0 {
+		t.Error("Expected invalid messages not to be sent")
+	}
+}
@@ -0,0 +1,419 @@
+package test
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+	"github.com/valyala/fasthttp"
+)
+
+// mockMNSClient 是一个内存版的 MNS 服务端，按 HTTP 资源路径模拟队列与主题的数据面接口
+type mockMNSClient struct {
+	lock              sync.Mutex
+	seq               int
+	visibilityTimeout time.Duration
+	queues            map[string][]*mockMessage
+	published         map[string][]mockSendMessage
+	requests          []string
+	injected          []mockError
+	failedOnce        map[string]bool
+	listing           map[string][]string
+}
+
+type mockMessage struct {
+	id            string
+	body          string
+	priority      int64
+	receiptHandle string
+	nextVisible   time.Time
+	enqueueTime   time.Time
+	dequeueCount  int64
+	userProps     ali_mns.MessageProperties
+}
+
+type mockError struct {
+	status int
+	code   string
+}
+
+type mockSendMessage struct {
+	XMLName        xml.Name                  `xml:"Message"`
+	MessageBody    string                    `xml:"MessageBody"`
+	DelaySeconds   int64                     `xml:"DelaySeconds"`
+	Priority       int64                     `xml:"Priority"`
+	UserProperties ali_mns.MessageProperties `xml:"UserProperties"`
+}
+
+type mockBatchSendMessage struct {
+	XMLName  xml.Name          `xml:"Messages"`
+	Messages []mockSendMessage `xml:"Message"`
+}
+
+func newMockMNSClient() *mockMNSClient {
+	return &mockMNSClient{
+		visibilityTimeout: 30 * time.Second,
+		queues:            map[string][]*mockMessage{},
+		published:         map[string][]mockSendMessage{},
+		failedOnce:        map[string]bool{},
+		listing:           map[string][]string{},
+	}
+}
+
+func (p *mockMNSClient) SetProxy(url string) {}
+
+func (p *mockMNSClient) SetTransport(transport fasthttp.RoundTripper) {}
+
+func (p *mockMNSClient) GetAccountId() string {
+	return "123456"
+}
+
+func (p *mockMNSClient) GetRegion() string {
+	return "cn-hangzhou"
+}
+
+// injectError 让接下来的一次请求返回指定的服务端错误
+func (p *mockMNSClient) injectError(status int, code string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.injected = append(p.injected, mockError{status: status, code: code})
+}
+
+// enqueue 直接向队列中放入一条消息，模拟其他 SDK 生产的消息
+func (p *mockMNSClient) enqueue(queueName string, body string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.enqueueLocked(queueName, mockSendMessage{MessageBody: body, Priority: 8})
+}
+
+func (p *mockMNSClient) messages(queueName string) []mockMessage {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	messages := []mockMessage{}
+	for _, m := range p.queues[queueName] {
+		messages = append(messages, *m)
+	}
+	return messages
+}
+
+// makeVisible 让队列中所有延迟或处理中的消息立即可见，模拟时间流逝
+func (p *mockMNSClient) makeVisible(queueName string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, m := range p.queues[queueName] {
+		m.nextVisible = time.Now()
+	}
+}
+
+func (p *mockMNSClient) requestCount(prefix string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	count := 0
+	for _, r := range p.requests {
+		if strings.HasPrefix(r, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *mockMNSClient) Send(method ali_mns.Method, headers map[string]string, message interface{}, resource string) (*fasthttp.Response, error) {
+	var body []byte
+	if message != nil {
+		var err error
+		if body, err = xml.Marshal(message); err != nil {
+			return nil, err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.requests = append(p.requests, string(method)+" "+resource)
+
+	if len(p.injected) > 0 {
+		injected := p.injected[0]
+		p.injected = p.injected[1:]
+		return p.errorResponse(injected.status, injected.code), nil
+	}
+
+	path, rawQuery, _ := strings.Cut(resource, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	pieces := strings.Split(path, "/")
+
+	if _, ok := p.listing[path]; ok && method == ali_mns.GET {
+		return p.listPage(path, headers), nil
+	}
+
+	if len(pieces) == 3 && pieces[0] == "topics" && pieces[2] == "messages" && method == ali_mns.POST {
+		msg := mockSendMessage{}
+		if err := xml.Unmarshal(body, &msg); err != nil {
+			return p.errorResponse(400, "MalformedXML"), nil
+		}
+		p.published[pieces[1]] = append(p.published[pieces[1]], msg)
+		p.seq++
+		return p.xmlResponse(201, fmt.Sprintf("<Message><MessageId>%d</MessageId><MessageBodyMD5>%s</MessageBodyMD5></Message>", p.seq, bodyMD5(msg.MessageBody))), nil
+	}
+
+	if len(pieces) != 3 || pieces[0] != "queues" || pieces[2] != "messages" {
+		return p.errorResponse(404, "InvalidRequestURL"), nil
+	}
+	queueName := pieces[1]
+
+	switch {
+	case method == ali_mns.POST:
+		return p.sendMessages(queueName, body), nil
+	case method == ali_mns.GET:
+		return p.receiveMessages(queueName, query), nil
+	case method == ali_mns.DELETE && query.Get("ReceiptHandle") != "":
+		if p.findByHandle(queueName, query.Get("ReceiptHandle"), true) == nil {
+			return p.errorResponse(404, "ReceiptHandleError"), nil
+		}
+		return p.xmlResponse(204, ""), nil
+	case method == ali_mns.DELETE:
+		handles := ali_mns.ReceiptHandles{}
+		if err := xml.Unmarshal(body, &handles); err != nil {
+			return p.errorResponse(400, "MalformedXML"), nil
+		}
+		failed := ""
+		for _, handle := range handles.ReceiptHandles {
+			if p.findByHandle(queueName, handle, true) == nil {
+				failed += fmt.Sprintf("<Error><ErrorCode>ReceiptHandleError</ErrorCode><ErrorMessage>invalid handle</ErrorMessage><ReceiptHandle>%s</ReceiptHandle></Error>", handle)
+			}
+		}
+		if failed != "" {
+			return p.xmlResponse(404, "<Errors>"+failed+"</Errors>"), nil
+		}
+		return p.xmlResponse(204, ""), nil
+	case method == ali_mns.PUT:
+		m := p.findByHandle(queueName, query.Get("ReceiptHandle"), false)
+		if m == nil {
+			return p.errorResponse(404, "MessageNotExist"), nil
+		}
+		timeout, _ := strconv.Atoi(query.Get("VisibilityTimeout"))
+		p.seq++
+		m.receiptHandle = fmt.Sprintf("%s-h%d", m.id, p.seq)
+		m.nextVisible = time.Now().Add(time.Duration(timeout) * time.Second)
+		return p.xmlResponse(200, fmt.Sprintf("<ChangeVisibility><ReceiptHandle>%s</ReceiptHandle><NextVisibleTime>%d</NextVisibleTime></ChangeVisibility>",
+			m.receiptHandle, m.nextVisible.UnixMilli())), nil
+	}
+	return p.errorResponse(400, "InvalidArgument"), nil
+}
+
+func (p *mockMNSClient) sendMessages(queueName string, body []byte) *fasthttp.Response {
+	if strings.HasPrefix(string(body), "<Messages>") {
+		batch := mockBatchSendMessage{}
+		if err := xml.Unmarshal(body, &batch); err != nil {
+			return p.errorResponse(400, "MalformedXML")
+		}
+		entries := ""
+		failed := false
+		for _, msg := range batch.Messages {
+			// 以 fail: 开头的消息体模拟单条消息发送失败，冒号后为错误码；fail-once: 只失败一次
+			code, ok := strings.CutPrefix(msg.MessageBody, "fail:")
+			if once, isOnce := strings.CutPrefix(msg.MessageBody, "fail-once:"); isOnce && !p.failedOnce[msg.MessageBody] {
+				p.failedOnce[msg.MessageBody] = true
+				code, ok = once, true
+			}
+			if ok {
+				failed = true
+				entries += fmt.Sprintf("<Message><ErrorCode>%s</ErrorCode><ErrorMessage>injected failure</ErrorMessage></Message>", code)
+				continue
+			}
+			m := p.enqueueLocked(queueName, msg)
+			entries += fmt.Sprintf("<Message><MessageId>%s</MessageId><MessageBodyMD5>%s</MessageBodyMD5></Message>", m.id, bodyMD5(m.body))
+		}
+		if failed {
+			return p.xmlResponse(500, "<Messages>"+entries+"</Messages>")
+		}
+		return p.xmlResponse(201, "<Messages>"+entries+"</Messages>")
+	}
+
+	msg := mockSendMessage{}
+	if err := xml.Unmarshal(body, &msg); err != nil {
+		return p.errorResponse(400, "MalformedXML")
+	}
+	m := p.enqueueLocked(queueName, msg)
+	return p.xmlResponse(201, fmt.Sprintf("<Message><MessageId>%s</MessageId><MessageBodyMD5>%s</MessageBodyMD5></Message>", m.id, bodyMD5(m.body)))
+}
+
+// setListing 设置列表接口返回的资源名称，resource 为 queues、topics 或 topics/<name>/subscriptions
+func (p *mockMNSClient) setListing(resource string, names ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	p.listing[resource] = sorted
+}
+
+func (p *mockMNSClient) listPage(resource string, headers map[string]string) *fasthttp.Response {
+	limit := 1000
+	if n := headers["x-mns-ret-number"]; n != "" {
+		limit, _ = strconv.Atoi(n)
+	}
+
+	names := []string{}
+	for _, name := range p.listing[resource] {
+		if strings.HasPrefix(name, headers["x-mns-prefix"]) && name >= headers["x-mns-marker"] {
+			names = append(names, name)
+		}
+	}
+	nextMarker := ""
+	if len(names) > limit {
+		nextMarker = names[limit]
+		names = names[:limit]
+	}
+
+	root, element, urlElement, nameElement := "Queues", "Queue", "QueueURL", "QueueName"
+	if resource == "topics" {
+		root, element, urlElement, nameElement = "Topics", "Topic", "TopicURL", "TopicName"
+	} else if strings.HasSuffix(resource, "/subscriptions") {
+		root, element, urlElement, nameElement = "Subscriptions", "Subscription", "SubscriptionURL", "SubscriptionName"
+	}
+	if headers["x-mns-with-meta"] != "true" {
+		nameElement = ""
+	}
+
+	entries := ""
+	for _, name := range names {
+		if nameElement != "" {
+			entries += fmt.Sprintf("<%s><%s>%s</%s></%s>", element, nameElement, name, nameElement, element)
+		} else {
+			entries += fmt.Sprintf("<%s><%s>http://mock/%s/%s</%s></%s>", element, urlElement, resource, name, urlElement, element)
+		}
+	}
+	return p.xmlResponse(200, fmt.Sprintf("<%s>%s<NextMarker>%s</NextMarker></%s>", root, entries, nextMarker, root))
+}
+
+func (p *mockMNSClient) enqueueLocked(queueName string, msg mockSendMessage) *mockMessage {
+	p.seq++
+	now := time.Now()
+	m := &mockMessage{
+		id:          fmt.Sprintf("msg-%d", p.seq),
+		body:        msg.MessageBody,
+		priority:    msg.Priority,
+		enqueueTime: now,
+		nextVisible: now.Add(time.Duration(msg.DelaySeconds) * time.Second),
+		userProps:   msg.UserProperties,
+	}
+	p.queues[queueName] = append(p.queues[queueName], m)
+	return m
+}
+
+func (p *mockMNSClient) receiveMessages(queueName string, query url.Values) *fasthttp.Response {
+	num := 1
+	if n := query.Get("numOfMessages"); n != "" {
+		num, _ = strconv.Atoi(n)
+	}
+	peek := query.Get("peekonly") == "true"
+
+	now := time.Now()
+	visible := []*mockMessage{}
+	for _, m := range p.queues[queueName] {
+		if !m.nextVisible.After(now) {
+			visible = append(visible, m)
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool { return visible[i].priority < visible[j].priority })
+	if len(visible) == 0 {
+		return p.errorResponse(404, "MessageNotExist")
+	}
+	if len(visible) > num {
+		visible = visible[:num]
+	}
+
+	entries := ""
+	for _, m := range visible {
+		if !peek {
+			p.seq++
+			m.dequeueCount++
+			m.receiptHandle = fmt.Sprintf("%s-h%d", m.id, p.seq)
+			m.nextVisible = now.Add(p.visibilityTimeout)
+		}
+		entries += p.messageXML(m)
+	}
+
+	if query.Get("numOfMessages") == "" {
+		return p.xmlResponse(200, entries)
+	}
+	return p.xmlResponse(200, "<Messages>"+entries+"</Messages>")
+}
+
+func (p *mockMNSClient) messageXML(m *mockMessage) string {
+	props := ""
+	if len(m.userProps) > 0 {
+		b, _ := xml.Marshal(struct {
+			XMLName xml.Name                  `xml:"Wrapper"`
+			Props   ali_mns.MessageProperties `xml:"UserProperties"`
+		}{Props: m.userProps})
+		props = strings.TrimSuffix(strings.TrimPrefix(string(b), "<Wrapper>"), "</Wrapper>")
+	}
+
+	body := &strings.Builder{}
+	xml.EscapeText(body, []byte(m.body))
+	return fmt.Sprintf("<Message><MessageId>%s</MessageId><ReceiptHandle>%s</ReceiptHandle><MessageBodyMD5>%s</MessageBodyMD5>"+
+		"<MessageBody>%s</MessageBody><EnqueueTime>%d</EnqueueTime><NextVisibleTime>%d</NextVisibleTime><DequeueCount>%d</DequeueCount>"+
+		"<Priority>%d</Priority>%s</Message>",
+		m.id, m.receiptHandle, bodyMD5(m.body), body.String(), m.enqueueTime.UnixMilli(), m.nextVisible.UnixMilli(), m.dequeueCount, m.priority, props)
+}
+
+func (p *mockMNSClient) findByHandle(queueName string, handle string, remove bool) *mockMessage {
+	messages := p.queues[queueName]
+	for i, m := range messages {
+		if m.receiptHandle != "" && m.receiptHandle == handle {
+			if remove {
+				p.queues[queueName] = append(messages[:i:i], messages[i+1:]...)
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+func (p *mockMNSClient) errorResponse(status int, code string) *fasthttp.Response {
+	return p.xmlResponse(status, fmt.Sprintf("<Error><Code>%s</Code><Message>mock error</Message><RequestId>mock</RequestId><HostId>mock</HostId></Error>", code))
+}
+
+func (p *mockMNSClient) xmlResponse(status int, body string) *fasthttp.Response {
+	resp := fasthttp.AcquireResponse()
+	resp.SetStatusCode(status)
+	resp.SetBodyString(body)
+	return resp
+}
+
+func bodyMD5(body string) string {
+	return strings.ToUpper(fmt.Sprintf("%x", md5.Sum([]byte(body))))
+}
+
+// receiveOne 通过通道接口同步地接收一条消息
+func receiveOne(queue ali_mns.AliMNSQueue) (ali_mns.MessageReceiveResponse, error) {
+	respChan := make(chan ali_mns.MessageReceiveResponse, 1)
+	errChan := make(chan error, 1)
+	queue.ReceiveMessage(respChan, errChan)
+	select {
+	case resp := <-respChan:
+		return resp, nil
+	case err := <-errChan:
+		return ali_mns.MessageReceiveResponse{}, err
+	}
+}
+
+// batchReceive 通过通道接口同步地批量接收消息
+func batchReceive(queue ali_mns.AliMNSQueue, num int32) (ali_mns.BatchMessageReceiveResponse, error) {
+	respChan := make(chan ali_mns.BatchMessageReceiveResponse, 1)
+	errChan := make(chan error, 1)
+	queue.BatchReceiveMessage(respChan, errChan, num)
+	select {
+	case resp := <-respChan:
+		return resp, nil
+	case err := <-errChan:
+		return ali_mns.BatchMessageReceiveResponse{}, err
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.23
+
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestAllQueuesIterator(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("queues", queueNames(7, "q-")...)
+
+	count := 0
+	for queue, err := range ali_mns.AllQueues(context.Background(), ali_mns.NewMNSQueueManager(client), "", 3) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if queue.QueueURL == "" {
+			t.Error("Expected queue url")
+		}
+		count++
+	}
+	if count != 7 {
+		t.Errorf("Expected 7 queues, got %d", count)
+	}
+}
+
+func TestAllTopicsIteratorError(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("topics", queueNames(7, "t-")...)
+	client.injectError(500, "InternalError")
+
+	// 出错时只产出一次错误然后结束
+	var errs []error
+	for _, err := range ali_mns.AllTopics(context.Background(), ali_mns.NewMNSTopicManager(client), "", 3) {
+		errs = append(errs, err)
+	}
+	var pageErr *ali_mns.PageError
+	if len(errs) != 1 || !errors.As(errs[0], &pageErr) || pageErr.Page != 1 {
+		t.Errorf("Expected a single page 1 error, got %v", errs)
+	}
+}
+
+func TestAllSubscriptionsIteratorBreak(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("topics/t/subscriptions", queueNames(10, "s-")...)
+	topic, _ := ali_mns.NewMNSTopic("t", client)
+
+	for range ali_mns.AllSubscriptions(context.Background(), topic, "", 2) {
+		break
+	}
+	if n := client.requestCount("GET"); n != 1 {
+		t.Errorf("Expected break to stop fetching pages, got %d requests", n)
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func queueNames(n int, prefix string) []string {
+	names := []string{}
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("%s%02d", prefix, i))
+	}
+	return names
+}
+
+func TestQueuePaginator(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("queues", append(queueNames(25, "order-"), queueNames(3, "audit-")...)...)
+	manager := ali_mns.NewMNSQueueManager(client)
+
+	queues, err := ali_mns.NewQueuePaginator(manager, "order-", 10).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list queues: %v", err)
+	}
+	// 25 个队列分 3 页返回，前缀过滤掉其他队列
+	if len(queues) != 25 {
+		t.Fatalf("Expected 25 queues, got %d", len(queues))
+	}
+	if !strings.HasSuffix(queues[24].QueueURL, "order-24") {
+		t.Errorf("Unexpected last queue %s", queues[24].QueueURL)
+	}
+	if n := client.requestCount("GET queues"); n != 3 {
+		t.Errorf("Expected 3 page requests, got %d", n)
+	}
+}
+
+func TestDetailPaginators(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("queues", queueNames(5, "q-")...)
+	client.setListing("topics", queueNames(5, "t-")...)
+	client.setListing("topics/t-00/subscriptions", queueNames(5, "s-")...)
+
+	queues, err := ali_mns.NewQueueDetailPaginator(ali_mns.NewMNSQueueManager(client), "", 2).Collect(context.Background())
+	if err != nil || len(queues) != 5 || queues[4].QueueName != "q-04" {
+		t.Errorf("Unexpected queue details: %+v, %v", queues, err)
+	}
+
+	topics, err := ali_mns.NewTopicDetailPaginator(ali_mns.NewMNSTopicManager(client), "", 2).Collect(context.Background())
+	if err != nil || len(topics) != 5 || topics[0].TopicName != "t-00" {
+		t.Errorf("Unexpected topic details: %+v, %v", topics, err)
+	}
+
+	topic, _ := ali_mns.NewMNSTopic("t-00", client)
+	subscriptions, err := ali_mns.NewSubscriptionPaginator(topic, "", 2).Collect(context.Background())
+	if err != nil || len(subscriptions) != 5 {
+		t.Errorf("Unexpected subscriptions: %+v, %v", subscriptions, err)
+	}
+	details, err := ali_mns.NewSubscriptionDetailPaginator(topic, "s-0", 3).Collect(context.Background())
+	if err != nil || len(details) != 5 || details[2].SubscriptionName != "s-02" {
+		t.Errorf("Unexpected subscription details: %+v, %v", details, err)
+	}
+}
+
+func TestPaginatorPageError(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("topics", queueNames(10, "t-")...)
+	paginator := ali_mns.NewTopicPaginator(ali_mns.NewMNSTopicManager(client), "", 4)
+
+	if _, err := paginator.NextPage(context.Background()); err != nil {
+		t.Fatalf("Failed to fetch first page: %v", err)
+	}
+
+	// 第二页失败时错误中带有页码和 marker
+	client.injectError(500, "InternalError")
+	_, err := paginator.NextPage(context.Background())
+	var pageErr *ali_mns.PageError
+	if !errors.As(err, &pageErr) || pageErr.Page != 2 || pageErr.Marker != "t-04" {
+		t.Fatalf("Expected error at page 2, got %v", err)
+	}
+	if !ali_mns.ERR_MNS_INTERNAL_ERROR.IsEqual(pageErr.Err) {
+		t.Errorf("Expected ERR_MNS_INTERNAL_ERROR, got %v", pageErr.Err)
+	}
+
+	// 失败后可以从同一页继续
+	rest, err := paginator.Collect(context.Background())
+	if err != nil || len(rest) != 6 {
+		t.Errorf("Expected remaining 6 topics, got %d, %v", len(rest), err)
+	}
+}
+
+func TestPaginatorContextCancel(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("queues", queueNames(10, "q-")...)
+	paginator := ali_mns.NewQueuePaginator(ali_mns.NewMNSQueueManager(client), "", 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	err := paginator.ForEach(ctx, func(queue ali_mns.Queue) error {
+		count++
+		if count == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected to stop after the current page, got %d items", count)
+	}
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestProducerBatchesMessages(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("producer-queue", client)
+	producer, err := ali_mns.NewProducer(queue, ali_mns.WithProducerLinger(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+
+	futures := []*ali_mns.SendFuture{}
+	for i := 0; i < 20; i++ {
+		futures = append(futures, producer.Send(ali_mns.MessageSendRequest{MessageBody: fmt.Sprintf("body-%d", i), Priority: 8}))
+	}
+	producer.Close()
+
+	// 20 条消息分为 16 + 4 两个批次
+	if n := client.requestCount("POST"); n != 2 {
+		t.Errorf("Expected 2 batch send requests, got %d", n)
+	}
+	// 两个批次并发发送，按消息 ID 找到对应的消息体
+	bodies := map[string]string{}
+	for _, m := range client.messages("producer-queue") {
+		bodies[m.id] = m.body
+	}
+	for i, future := range futures {
+		resp, err := future.Result()
+		if err != nil {
+			t.Fatalf("Message %d: unexpected error %v", i, err)
+		}
+		if bodies[resp.MessageId] != fmt.Sprintf("body-%d", i) {
+			t.Errorf("Message %d: id %s belongs to %q", i, resp.MessageId, bodies[resp.MessageId])
+		}
+	}
+}
+
+func TestProducerMaxBatchBytes(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("producer-queue", client)
+	producer, _ := ali_mns.NewProducer(queue, ali_mns.WithProducerLinger(time.Hour), ali_mns.WithProducerMaxBatchBytes(1000))
+
+	for i := 0; i < 3; i++ {
+		producer.Send(ali_mns.MessageSendRequest{MessageBody: strings.Repeat("x", 400)})
+	}
+	producer.Close()
+
+	// 每批不超过 1000 字节，3 条 400 字节的消息分两批发送
+	if n := client.requestCount("POST"); n != 2 {
+		t.Errorf("Expected 2 batch send requests, got %d", n)
+	}
+}
+
+func TestProducerLinger(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("producer-queue", client)
+	producer, _ := ali_mns.NewProducer(queue, ali_mns.WithProducerLinger(20*time.Millisecond))
+	defer producer.Close()
+
+	future := producer.Send(ali_mns.MessageSendRequest{MessageBody: "body"})
+	select {
+	case <-future.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected message to be sent after linger")
+	}
+	if _, err := future.Result(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestProducerEntryFailure(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("producer-queue", client)
+	producer, _ := ali_mns.NewProducer(queue, ali_mns.WithProducerLinger(time.Hour))
+
+	ok := producer.Send(ali_mns.MessageSendRequest{MessageBody: "ok"})
+	failed := producer.Send(ali_mns.MessageSendRequest{MessageBody: "fail:MessageTooLarge"})
+	producer.Close()
+
+	if resp, err := ok.Result(); err != nil || resp.MessageId == "" {
+		t.Errorf("Expected first message to succeed, got %v", err)
+	}
+	// 失败的消息按下标对应到自己的错误码
+	var entryErr *ali_mns.BatchEntryError
+	if _, err := failed.Result(); !errors.As(err, &entryErr) || entryErr.ErrorCode != "MessageTooLarge" || entryErr.Index != 1 {
+		t.Errorf("Expected MessageTooLarge entry error, got %v", err)
+	}
+
+	if _, err := producer.Send(ali_mns.MessageSendRequest{MessageBody: "late"}).Result(); err == nil {
+		t.Error("Expected send after close to fail")
+	}
+}
+
+func TestProducerInvalidPropertiesFailAlone(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("producer-queue", client)
+	producer, _ := ali_mns.NewProducer(queue, ali_mns.WithProducerLinger(time.Hour))
+
+	valid := producer.Send(ali_mns.MessageSendRequest{MessageBody: "valid"})
+	invalid := producer.Send(ali_mns.MessageSendRequest{MessageBody: "invalid",
+		UserProperties: ali_mns.MessageProperties{{Name: "n", Value: "abc", Type: ali_mns.NUMBER_PROPERTY}}})
+	producer.Close()
+
+	// 属性非法的消息单独失败，不影响同批次的其他消息
+	if _, err := invalid.Result(); !ali_mns.ERR_MNS_INVALID_MESSAGE_PROPERTY.IsEqual(err) {
+		t.Errorf("Expected ERR_MNS_INVALID_MESSAGE_PROPERTY, got %v", err)
+	}
+	if _, err := valid.Result(); err != nil {
+		t.Errorf("Expected valid message to be sent, got %v", err)
+	}
+	if n := len(client.messages("producer-queue")); n != 1 {
+		t.Errorf("Expected 1 sent message, got %d", n)
+	}
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestRateLimiterBurstAndRate(t *testing.T) {
+	limiter := ali_mns.NewRateLimiter(20, 5)
+
+	// 突发额度内不等待，之后按速率放行
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected about 500ms for 10 requests beyond the burst, got %v", elapsed)
+	}
+}
+
+func TestRateLimiterFIFO(t *testing.T) {
+	limiter := ali_mns.NewRateLimiter(50, 1)
+	limiter.Wait(context.Background())
+
+	var lock sync.Mutex
+	order := []int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limiter.Wait(context.Background())
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+		}(i)
+		time.Sleep(2 * time.Millisecond)
+	}
+	wg.Wait()
+
+	// 按到达顺序放行
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("Expected FIFO order, got %v", order)
+		}
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	limiter := ali_mns.NewRateLimiter(1, 1)
+	limiter.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected wait to stop on cancel, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := ali_mns.NewRateLimiter(0, 0)
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		limiter.Wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected no wait, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterSharedByQueueAndTopic(t *testing.T) {
+	client := newMockMNSClient()
+	limiter := ali_mns.NewRateLimiter(20, 1)
+	queue, _ := ali_mns.NewMNSQueueWithOptions("limited-queue", client, ali_mns.WithRateLimiter(limiter))
+	topic, _ := ali_mns.NewMNSTopicWithOptions("limited-topic", client, ali_mns.WithRateLimiter(limiter))
+
+	// 队列和主题共享同一个限流器
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"})
+		topic.PublishMessage(ali_mns.MessagePublishRequest{MessageBody: "body"})
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected 10 requests at 20 qps to take about 450ms, got %v", elapsed)
+	}
+	if queue.QPSMonitor().RateLimiter() != limiter {
+		t.Error("Expected queue monitor to use the shared limiter")
+	}
+}
+
+func TestRateLimitedClient(t *testing.T) {
+	client := ali_mns.NewRateLimitedClient(newMockMNSClient(), ali_mns.NewRateLimiter(20, 1))
+	queue, _ := ali_mns.NewMNSQueue("limited-queue", client)
+	manager := ali_mns.NewMNSQueueManager(client)
+
+	// 客户端级别的限流覆盖队列和管理接口
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"})
+		manager.GetQueueAttributes("limited-queue")
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected 10 requests at 20 qps to take about 450ms, got %v", elapsed)
+	}
+}
+
+func TestQPSMonitorCompatibility(t *testing.T) {
+	monitor := ali_mns.NewQPSMonitor(5, 100)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				monitor.Pulse()
+				monitor.QPS()
+			}
+		}()
+	}
+	wg.Wait()
+	if monitor.RateLimiter() == nil || monitor.RateLimiter().Rate() != 100 {
+		t.Error("Expected a limiter of 100 qps")
+	}
+	if ali_mns.NewQPSMonitor(5, 0).RateLimiter() != nil {
+		t.Error("Expected no limiter without qps limit")
+	}
+}
+
+func TestPlaneRateLimitedClient(t *testing.T) {
+	mock := newMockMNSClient()
+	mock.setListing("queues", queueNames(3, "q-")...)
+	control := ali_mns.NewRateLimiter(5, 1)
+	client := ali_mns.NewPlaneRateLimitedClient(mock, control, nil)
+	queue, _ := ali_mns.NewMNSQueue("plane-queue", client)
+	manager := ali_mns.NewMNSQueueManager(client)
+
+	// 管理接口耗尽控制面额度
+	manager.ListQueue("", 0, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := control.Wait(ctx); err == nil {
+		t.Fatal("Expected control plane limiter to be exhausted")
+	}
+
+	// 消息接口不受控制面限流影响
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if _, err := queue.SendMessage(ali_mns.MessageSendRequest{MessageBody: "body"}); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected data plane not to wait for control plane, took %v", elapsed)
+	}
+}
+
+func TestTopicSubscriptionCallsRateLimited(t *testing.T) {
+	client := newMockMNSClient()
+	client.setListing("topics/limited-topic/subscriptions", queueNames(3, "s-")...)
+	limiter := ali_mns.NewRateLimiter(20, 1)
+	topic, _ := ali_mns.NewMNSTopicWithOptions("limited-topic", client, ali_mns.WithRateLimiter(limiter))
+
+	// 订阅相关接口同样经过限流
+	start := time.Now()
+	topic.GetSubscriptionAttributes("s-0")
+	topic.Unsubscribe("s-0")
+	topic.ListSubscriptionByTopic("", 0, "")
+	topic.ListSubscriptionDetailByTopic("", 0, "")
+	topic.GetSubscriptionAttributes("s-1")
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 5 requests at 20 qps to take about 200ms, got %v", elapsed)
+	}
+}
+
+func TestClientConfigPlaneLimiters(t *testing.T) {
+	client, err := ali_mns.NewAliMNSClientWithConfig(ali_mns.AliMNSClientConfig{
+		EndPoint:            "http://123456.mns.cn-hangzhou.aliyuncs.com",
+		AccessKeyId:         "ak",
+		AccessKeySecret:     "sk",
+		Region:              "cn-hangzhou",
+		ControlPlaneLimiter: ali_mns.NewRateLimiter(10, 1),
+		DataPlaneLimiter:    ali_mns.NewRateLimiter(100, 10),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.GetAccountId() != "123456" || client.GetRegion() != "cn-hangzhou" {
+		t.Errorf("Expected limited client to keep account and region, got %s %s", client.GetAccountId(), client.GetRegion())
+	}
+}
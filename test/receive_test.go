@@ -0,0 +1,129 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func newReceiver(t *testing.T, client *mockMNSClient, options ...ali_mns.MNSOption) ali_mns.AliMNSReceiver {
+	queue, err := ali_mns.NewMNSQueueWithOptions("receive-queue", client, options...)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	receiver, ok := queue.(ali_mns.AliMNSReceiver)
+	if !ok {
+		t.Fatal("Expected MNSQueue to implement AliMNSReceiver")
+	}
+	return receiver
+}
+
+func TestReceiveEmptyQueue(t *testing.T) {
+	receiver := newReceiver(t, newMockMNSClient())
+
+	// 队列为空时返回空结果而不是错误
+	msg, err := receiver.Receive(ali_mns.ReceiveOptions{})
+	if err != nil || msg != nil {
+		t.Errorf("Expected nil message without error, got %v, %v", msg, err)
+	}
+	batch, err := receiver.BatchReceive(ali_mns.ReceiveOptions{NumOfMessages: 4})
+	if err != nil || len(batch.Messages) != 0 {
+		t.Errorf("Expected empty batch without error, got %v, %v", batch, err)
+	}
+	if msg, err = receiver.Peek(); err != nil || msg != nil {
+		t.Errorf("Expected nil peek result, got %v, %v", msg, err)
+	}
+}
+
+func TestReceiveAndPeek(t *testing.T) {
+	client := newMockMNSClient()
+	receiver := newReceiver(t, client)
+	client.enqueue("receive-queue", "a")
+	client.enqueue("receive-queue", "b")
+	client.enqueue("receive-queue", "c")
+
+	peeked, err := receiver.BatchPeek(16)
+	if err != nil || len(peeked.Messages) != 3 {
+		t.Fatalf("Expected 3 peeked messages, got %v, %v", peeked, err)
+	}
+
+	msg, err := receiver.Receive(ali_mns.ReceiveOptions{WaitSeconds: 5})
+	if err != nil || msg == nil || msg.MessageBody != "a" {
+		t.Fatalf("Expected message a, got %v, %v", msg, err)
+	}
+	batch, err := receiver.BatchReceive(ali_mns.ReceiveOptions{NumOfMessages: 16})
+	if err != nil || len(batch.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %v, %v", batch, err)
+	}
+
+	requests := strings.Join(client.requests, "\n")
+	for _, resource := range []string{
+		"GET queues/receive-queue/messages?numOfMessages=16&peekonly=true",
+		"GET queues/receive-queue/messages?waitseconds=5",
+		"GET queues/receive-queue/messages?numOfMessages=16",
+	} {
+		if !strings.Contains(requests, resource) {
+			t.Errorf("Expected request %s, got\n%s", resource, requests)
+		}
+	}
+}
+
+func TestReceiveInvalidOptions(t *testing.T) {
+	client := newMockMNSClient()
+	receiver := newReceiver(t, client)
+
+	if _, err := receiver.Receive(ali_mns.ReceiveOptions{WaitSeconds: 31}); !ali_mns.ERR_MNS_MSG_POOLLING_WAIT_SECONDS_RANGE_ERROR.IsEqual(err) {
+		t.Errorf("Expected wait seconds range error, got %v", err)
+	}
+	// 超出 int32 范围的值不能截断后通过校验
+	if _, err := receiver.Receive(ali_mns.ReceiveOptions{WaitSeconds: 1<<32 + 5}); !ali_mns.ERR_MNS_MSG_POOLLING_WAIT_SECONDS_RANGE_ERROR.IsEqual(err) {
+		t.Errorf("Expected wait seconds range error for out of range value, got %v", err)
+	}
+	if _, err := receiver.BatchReceive(ali_mns.ReceiveOptions{NumOfMessages: 17}); err == nil {
+		t.Error("Expected number of messages range error")
+	}
+	if len(client.requests) != 0 {
+		t.Error("Expected invalid options not to send requests")
+	}
+}
+
+func TestReceiveServiceError(t *testing.T) {
+	client := newMockMNSClient()
+	receiver := newReceiver(t, client)
+	client.injectError(404, "QueueNotExist")
+
+	if _, err := receiver.Receive(ali_mns.ReceiveOptions{}); !ali_mns.ERR_MNS_QUEUE_NOT_EXIST.IsEqual(err) {
+		t.Errorf("Expected ERR_MNS_QUEUE_NOT_EXIST, got %v", err)
+	}
+}
+
+func TestReceiveDecodeError(t *testing.T) {
+	client := newMockMNSClient()
+	receiver := newReceiver(t, client, ali_mns.WithMessageBodyCodec(ali_mns.NewGzipBodyCodec(64)))
+	client.enqueue("receive-queue", "MNS1|gzip||broken")
+
+	// 解码失败时同时返回原始消息和错误
+	msg, err := receiver.Receive(ali_mns.ReceiveOptions{})
+	var decodeErr *ali_mns.MessageBodyDecodeError
+	if !errors.As(err, &decodeErr) || msg == nil || msg.ReceiptHandle == "" {
+		t.Errorf("Expected raw message with decode error, got %v, %v", msg, err)
+	}
+}
+
+func TestChannelReceiveOnEmptyQueue(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("receive-queue", client)
+
+	// 通道接口保持原有行为：每次等待都会上报 MessageNotExist
+	respChan := make(chan ali_mns.MessageReceiveResponse, 1)
+	errChan := make(chan error, 2)
+	queue.ReceiveMessage(respChan, errChan, 1, 2)
+	if len(errChan) != 2 || len(respChan) != 0 {
+		t.Fatalf("Expected 2 errors, got %d", len(errChan))
+	}
+	if err := <-errChan; !ali_mns.ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+		t.Errorf("Expected ERR_MNS_MESSAGE_NOT_EXIST, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestRedeliveryBackoffDelay(t *testing.T) {
+	backoff, err := ali_mns.NewRedeliveryBackoff(time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create backoff: %v", err)
+	}
+
+	// 延迟按接收次数指数增长并封顶
+	expected := map[int64]time.Duration{0: time.Second, 1: 2 * time.Second, 3: 8 * time.Second, 5: 32 * time.Second, 6: time.Minute, 100: time.Minute}
+	for count, delay := range expected {
+		if got := backoff.Delay(count); got != delay {
+			t.Errorf("Expected delay %v for dequeue count %d, got %v", delay, count, got)
+		}
+	}
+}
+
+func TestRedeliveryBackoffNack(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("redelivery-queue", client)
+	client.enqueue("redelivery-queue", "body")
+	backoff, _ := ali_mns.NewRedeliveryBackoff(2*time.Second, time.Hour)
+
+	msg, _ := receiveOne(queue)
+	start := time.Now()
+	if _, err := backoff.Nack(queue, msg); err != nil {
+		t.Fatalf("Failed to nack: %v", err)
+	}
+	// 第一次接收后延迟 2s * 2^1
+	next := client.messages("redelivery-queue")[0].nextVisible
+	if next.Before(start.Add(3*time.Second)) || next.After(start.Add(5*time.Second)) {
+		t.Errorf("Expected message visible again in 4s, got %v", next.Sub(start))
+	}
+}
+
+func TestRedeliveryBackoffInvalid(t *testing.T) {
+	if _, err := ali_mns.NewRedeliveryBackoff(500*time.Millisecond, time.Minute); err == nil {
+		t.Error("Expected error for base below 1s")
+	}
+	if _, err := ali_mns.NewRedeliveryBackoff(time.Minute, time.Second); err == nil {
+		t.Error("Expected error for max below base")
+	}
+	if _, err := ali_mns.NewRedeliveryBackoff(time.Second, 43201*time.Second); err == nil {
+		t.Error("Expected error for max above 43200s")
+	}
+}
+
+func TestConsumerRedeliveryBackoff(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("redelivery-queue", client)
+	client.enqueue("redelivery-queue", "body")
+	backoff, _ := ali_mns.NewRedeliveryBackoff(10*time.Second, time.Hour)
+
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		return errors.New("failed")
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond),
+		ali_mns.WithConsumerRedeliveryBackoff(backoff))
+
+	// 处理失败后按退避时间而不是队列的可见时间重新投递
+	start := time.Now()
+	runConsumer(t, consumer, func() bool { return client.requestCount("PUT") == 1 })
+	next := client.messages("redelivery-queue")[0].nextVisible
+	if next.Before(start.Add(19*time.Second)) || next.After(start.Add(21*time.Second)) {
+		t.Errorf("Expected message visible again in 20s, got %v", next.Sub(start))
+	}
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestSendMessageAtWithinMaxDelay(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("scheduled-queue", client)
+
+	deliverAt := time.Now().Add(time.Hour)
+	if _, err := ali_mns.SendMessageAt(queue, ali_mns.MessageSendRequest{MessageBody: "body"}, deliverAt); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	// 最大延迟以内直接使用延迟消息，不附加调度属性
+	messages := client.messages("scheduled-queue")
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	if messages[0].nextVisible.Before(deliverAt) || messages[0].nextVisible.After(deliverAt.Add(2*time.Second)) {
+		t.Errorf("Expected message visible at %v, got %v", deliverAt, messages[0].nextVisible)
+	}
+	if _, ok := messages[0].userProps.Get(ali_mns.ScheduledDeliverAtProperty); ok {
+		t.Error("Expected no scheduled property")
+	}
+}
+
+func TestSendMessageAtBeyondMaxDelay(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("scheduled-queue", client)
+
+	deliverAt := time.Now().Add(10 * 24 * time.Hour)
+	resp, err := ali_mns.SendMessageAt(queue, ali_mns.MessageSendRequest{MessageBody: "body"}, deliverAt)
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	maxDelay := time.Duration(ali_mns.MaxDelaySeconds) * time.Second
+	messages := client.messages("scheduled-queue")
+	if len(messages) != 1 || messages[0].nextVisible.Before(time.Now().Add(maxDelay-time.Minute)) {
+		t.Fatalf("Expected a message delayed by the max delay, got %+v", messages)
+	}
+
+	var handled int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond))
+
+	// 中间跳被重新入队且不交给处理函数
+	client.makeVisible("scheduled-queue")
+	runConsumer(t, consumer, func() bool {
+		messages := client.messages("scheduled-queue")
+		return len(messages) == 1 && messages[0].id != resp.MessageId
+	})
+	if atomic.LoadInt32(&handled) != 0 {
+		t.Errorf("Expected hop not to be handled, got %d", handled)
+	}
+	next := client.messages("scheduled-queue")[0]
+	if id, _ := next.userProps.Get(ali_mns.ScheduledMessageIdProperty); id != resp.MessageId {
+		t.Errorf("Expected original message id %s, got %s", resp.MessageId, id)
+	}
+	if _, ok := next.userProps.Get(ali_mns.ScheduledDeliverAtProperty); !ok || next.nextVisible.Before(time.Now().Add(maxDelay-time.Minute)) {
+		t.Errorf("Expected another max delay hop, got %+v", next)
+	}
+}
+
+func TestScheduledLastHop(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("scheduled-queue", client)
+
+	// 模拟经过多跳之后，距离投递时间已不足最大延迟
+	deliverAt := time.Now().Add(time.Hour)
+	request := ali_mns.MessageSendRequest{MessageBody: "body"}
+	request.UserProperties.SetTyped(ali_mns.ScheduledDeliverAtProperty, strconv.FormatInt(deliverAt.UnixMilli(), 10), ali_mns.NUMBER_PROPERTY)
+	queue.SendMessage(request)
+
+	var handled int32
+	consumer, _ := ali_mns.NewConsumer(queue, func(ctx context.Context, msg ali_mns.MessageReceiveResponse) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, ali_mns.WithConsumerWaitSeconds(0), ali_mns.WithConsumerErrorDelay(10*time.Millisecond))
+
+	runConsumer(t, consumer, func() bool { return client.requestCount("DELETE") == 1 })
+	next := client.messages("scheduled-queue")[0]
+	if _, ok := next.userProps.Get(ali_mns.ScheduledDeliverAtProperty); ok {
+		t.Error("Expected last hop without scheduled property")
+	}
+	if next.nextVisible.Before(deliverAt) || next.nextVisible.After(deliverAt.Add(2*time.Second)) {
+		t.Errorf("Expected last hop visible at %v, got %v", deliverAt, next.nextVisible)
+	}
+
+	// 最后一跳正常交给处理函数
+	client.makeVisible("scheduled-queue")
+	runConsumer(t, consumer, func() bool { return len(client.messages("scheduled-queue")) == 0 })
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Errorf("Expected message to be handled once, got %d", handled)
+	}
+}
+
+func TestSendMessageAtPast(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("scheduled-queue", client)
+
+	if _, err := ali_mns.SendMessageAt(queue, ali_mns.MessageSendRequest{MessageBody: "body", DelaySeconds: 60}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	// 过去的时间立即投递
+	if msg, err := receiveOne(queue); err != nil || msg.MessageBody != "body" {
+		t.Errorf("Expected message to be visible, got %v", err)
+	}
+}
+
+func TestReceiveForwardsScheduledHops(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("scheduled-queue", client)
+	receiver := queue.(ali_mns.AliMNSReceiver)
+
+	deliverAt := time.Now().Add(10 * 24 * time.Hour)
+	ali_mns.SendMessageAt(queue, ali_mns.MessageSendRequest{MessageBody: "hop"}, deliverAt)
+	client.makeVisible("scheduled-queue")
+
+	// 窥视不转发中间跳
+	if msg, err := receiver.Peek(); err != nil || msg == nil || msg.MessageBody != "hop" {
+		t.Fatalf("Expected peek to show the hop, got %+v, %v", msg, err)
+	}
+
+	// 普通接收时中间跳被转发，队列表现为空
+	if msg, err := receiver.Receive(ali_mns.ReceiveOptions{}); err != nil || msg != nil {
+		t.Errorf("Expected no message, got %+v, %v", msg, err)
+	}
+	if client.requestCount("DELETE") != 1 {
+		t.Errorf("Expected the hop to be deleted")
+	}
+
+	client.makeVisible("scheduled-queue")
+	client.enqueue("scheduled-queue", "normal")
+	resp, err := receiver.BatchReceive(ali_mns.ReceiveOptions{NumOfMessages: 16})
+	if err != nil || len(resp.Messages) != 1 || resp.Messages[0].MessageBody != "normal" {
+		t.Errorf("Expected only the normal message, got %+v, %v", resp.Messages, err)
+	}
+
+	// 通道接口与 TypedQueue 同样看不到中间跳
+	other, _ := ali_mns.NewMNSQueue("scheduled-queue-2", client)
+	ali_mns.SendMessageAt(other, ali_mns.MessageSendRequest{MessageBody: "hop"}, deliverAt)
+	client.makeVisible("scheduled-queue-2")
+	respChan := make(chan ali_mns.MessageReceiveResponse, 1)
+	errChan := make(chan error, 1)
+	other.ReceiveMessage(respChan, errChan)
+	if len(respChan) != 0 || len(errChan) != 1 || !ali_mns.ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(<-errChan) {
+		t.Error("Expected the channel API to report an empty queue")
+	}
+
+	client.makeVisible("scheduled-queue-2")
+	typed := ali_mns.NewTypedQueue[string](other, ali_mns.JSONCodec{})
+	if _, err := typed.ReceiveMessage(); !ali_mns.ERR_MNS_MESSAGE_NOT_EXIST.IsEqual(err) {
+		t.Errorf("Expected TypedQueue to report an empty queue, got %v", err)
+	}
+	if n := client.requestCount("DELETE"); n != 4 {
+		t.Errorf("Expected every receive to forward the hop, got %d deletes", n)
+	}
+}
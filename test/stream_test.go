@@ -0,0 +1,129 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+func TestQueueMessagesStream(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("stream-queue", client)
+	for _, body := range []string{"a", "b", "c"} {
+		client.enqueue("stream-queue", body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deliveries := queue.(*ali_mns.MNSQueue).Messages(ctx, ali_mns.StreamOptions{MinBackoff: 10 * time.Millisecond})
+
+	received := []string{}
+	for delivery := range deliveries {
+		received = append(received, delivery.MessageBody)
+		if err := delivery.Ack(); err != nil {
+			t.Errorf("Failed to ack: %v", err)
+		}
+		if len(received) == 3 {
+			cancel()
+		}
+	}
+
+	// ctx 取消后通道关闭，确认的消息被删除
+	if len(received) != 3 || received[0] != "a" || received[2] != "c" {
+		t.Errorf("Expected a, b, c, got %v", received)
+	}
+	if n := len(client.messages("stream-queue")); n != 0 {
+		t.Errorf("Expected acked messages to be deleted, got %d left", n)
+	}
+}
+
+func TestDeliveryNackAndExtend(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("stream-queue", client)
+	client.enqueue("stream-queue", "body")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delivery := <-queue.(*ali_mns.MNSQueue).Messages(ctx, ali_mns.StreamOptions{MinBackoff: 10 * time.Millisecond})
+
+	// 延长可见时间后回执句柄更新
+	if err := delivery.ExtendVisibility(time.Minute); err != nil {
+		t.Fatalf("Failed to extend: %v", err)
+	}
+	if delivery.Handle() == delivery.ReceiptHandle {
+		t.Error("Expected a new receipt handle after extending")
+	}
+	if err := delivery.ExtendVisibility(13 * time.Hour); !ali_mns.ERR_MNS_MSG_VISIBILITY_TIMEOUT_RANGE_ERROR.IsEqual(err) {
+		t.Errorf("Expected visibility range error, got %v", err)
+	}
+
+	start := time.Now()
+	if err := delivery.Nack(5 * time.Second); err != nil {
+		t.Fatalf("Failed to nack: %v", err)
+	}
+	next := client.messages("stream-queue")[0].nextVisible
+	if next.Before(start.Add(4*time.Second)) || next.After(start.Add(6*time.Second)) {
+		t.Errorf("Expected message visible again in 5s, got %v", next.Sub(start))
+	}
+
+	// 已经 Nack 的消息不能再 Ack
+	if err := delivery.Ack(); err == nil {
+		t.Error("Expected ack after nack to fail")
+	}
+}
+
+func TestQueueMessagesBackoffOnError(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("stream-queue", client)
+	client.injectError(500, "InternalError")
+	client.injectError(500, "InternalError")
+	client.enqueue("stream-queue", "body")
+
+	var lock sync.Mutex
+	var errs []error
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	deliveries := queue.(*ali_mns.MNSQueue).Messages(ctx, ali_mns.StreamOptions{
+		MinBackoff: 20 * time.Millisecond,
+		ErrorHandler: func(err error) {
+			lock.Lock()
+			errs = append(errs, err)
+			lock.Unlock()
+		},
+	})
+
+	// 出错后退避重试，退避时间翻倍
+	delivery := <-deliveries
+	if delivery.MessageBody != "body" {
+		t.Errorf("Expected body, got %q", delivery.MessageBody)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected backoff of 20ms and 40ms, got %v", elapsed)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 reported errors, got %v", errs)
+	}
+}
+
+func TestQueueMessagesInvalidOptions(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("stream-queue", client)
+
+	var reported error
+	deliveries := queue.(*ali_mns.MNSQueue).Messages(context.Background(), ali_mns.StreamOptions{
+		ReceiveOptions: ali_mns.ReceiveOptions{NumOfMessages: 17},
+		ErrorHandler:   func(err error) { reported = err },
+	})
+	if _, ok := <-deliveries; ok {
+		t.Error("Expected channel to be closed")
+	}
+	if reported == nil {
+		t.Error("Expected invalid options to be reported")
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/aliyun/aliyun-mns-go-sdk"
+)
+
+type orderEvent struct {
+	OrderId string `json:"order_id" msgpack:"order_id"`
+	Amount  int64  `json:"amount" msgpack:"amount"`
+}
+
+// counter 模拟 protobuf 风格的二进制消息
+type counter struct {
+	Value uint64
+}
+
+func (c *counter) Marshal() ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, c.Value), nil
+}
+
+func (c *counter) Unmarshal(data []byte) error {
+	if len(data) != 8 {
+		return fmt.Errorf("invalid counter length %d", len(data))
+	}
+	c.Value = binary.BigEndian.Uint64(data)
+	return nil
+}
+
+func TestTypedQueueCodecs(t *testing.T) {
+	codecs := map[string]ali_mns.Codec{
+		"json":    ali_mns.JSONCodec{},
+		"msgpack": ali_mns.MsgpackCodec{},
+	}
+
+	for name, codec := range codecs {
+		client := newMockMNSClient()
+		queue, _ := ali_mns.NewMNSQueue("typed-queue", client)
+		typed := ali_mns.NewTypedQueue[orderEvent](queue, codec)
+
+		event := orderEvent{OrderId: "o-1", Amount: 100}
+		if _, err := typed.SendMessage(event); err != nil {
+			t.Fatalf("%s: failed to send: %v", name, err)
+		}
+
+		msg, err := typed.ReceiveMessage()
+		if err != nil {
+			t.Fatalf("%s: failed to receive: %v", name, err)
+		}
+		if msg.Value != event {
+			t.Errorf("%s: expected %+v, got %+v", name, event, msg.Value)
+		}
+		if msg.Raw.ReceiptHandle == "" || msg.Raw.Priority != ali_mns.DefaultMessagePriority {
+			t.Errorf("%s: expected raw message to be kept, got %+v", name, msg.Raw)
+		}
+	}
+}
+
+func TestTypedQueueBinaryCodec(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("typed-queue", client)
+	typed := ali_mns.NewTypedQueue[*counter](queue, ali_mns.BinaryCodec{})
+
+	if _, err := typed.BatchSendMessage(&counter{Value: 1}, &counter{Value: 2}); err != nil {
+		t.Fatalf("Failed to batch send: %v", err)
+	}
+
+	msgs, err := typed.BatchReceiveMessage(16)
+	if err != nil {
+		t.Fatalf("Failed to batch receive: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Value.Value != 1 || msgs[1].Value.Value != 2 {
+		t.Errorf("Unexpected messages: %+v", msgs)
+	}
+}
+
+func TestTypedQueuePoisonMessage(t *testing.T) {
+	client := newMockMNSClient()
+	queue, _ := ali_mns.NewMNSQueue("typed-queue", client)
+	typed := ali_mns.NewTypedQueue[orderEvent](queue, ali_mns.JSONCodec{})
+
+	client.enqueue("typed-queue", "not json")
+	typed.SendMessage(orderEvent{OrderId: "o-2"})
+
+	msgs, err := typed.BatchReceiveMessage(16)
+	if err != nil {
+		t.Fatalf("Expected decode failures to be reported per message, got %v", err)
+	}
+	if msgs[0].Err == nil || msgs[0].Raw.MessageBody != "not json" {
+		t.Errorf("Expected poison message with raw body, got %+v", msgs[0])
+	}
+	if msgs[1].Err != nil || msgs[1].Value.OrderId != "o-2" {
+		t.Errorf("Expected second message to decode, got %+v", msgs[1])
+	}
+
+	// 毒消息可以通过原始句柄删除
+	if err = typed.DeleteMessage(msgs[0].Raw.ReceiptHandle); err != nil {
+		t.Errorf("Failed to delete poison message: %v", err)
+	}
+}
+
+func TestTypedTopicPublish(t *testing.T) {
+	client := newMockMNSClient()
+	topic, _ := ali_mns.NewMNSTopic("typed-topic", client)
+	typed := ali_mns.NewTypedTopic[orderEvent](topic, nil)
+
+	if _, err := typed.PublishMessage(orderEvent{OrderId: "o-3", Amount: 3}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	raw := ali_mns.MessageReceiveResponse{MessageBody: client.published["typed-topic"][0].MessageBody}
+	msg := ali_mns.DecodeTypedMessage[orderEvent](ali_mns.JSONCodec{}, raw)
+	if msg.Err != nil || msg.Value.OrderId != "o-3" {
+		t.Errorf("Failed to decode published message: %+v", msg)
+	}
+}
@@ -28,28 +28,43 @@ type MNSTopic struct {
 	name    string
 	client  MNSClient
 	decoder MNSDecoder
+	codecs  []MessageBodyCodec
 
-	qpsMonitor *QPSMonitor
+	qpsMonitor  *QPSMonitor
+	asyncSender *AsyncSender
 }
 
 func NewMNSTopic(name string, client MNSClient, qps ...int32) (AliMNSTopic, error) {
+	options := []MNSOption{}
+	if qps != nil && len(qps) == 1 {
+		options = append(options, WithQPSLimit(qps[0]))
+	}
+	return NewMNSTopicWithOptions(name, client, options...)
+}
+
+func NewMNSTopicWithOptions(name string, client MNSClient, options ...MNSOption) (AliMNSTopic, error) {
 	if name == "" {
 		return nil, fmt.Errorf("ali_mns: topic name could not be empty")
 	}
 
+	o := newMNSOptions(DefaultTopicQPSLimit, options...)
+
 	topic := new(MNSTopic)
-	topic.client = client
+	topic.client = o.mnsClient(client)
 	topic.name = name
 	topic.decoder = NewAliMNSDecoder()
-
-	qpsLimit := DefaultTopicQPSLimit
-	if qps != nil && len(qps) == 1 && qps[0] > 0 {
-		qpsLimit = qps[0]
-	}
-	topic.qpsMonitor = NewQPSMonitor(5, qpsLimit)
+	topic.codecs = o.bodyCodecs()
+	topic.qpsMonitor = o.qpsMonitor()
+	topic.asyncSender = o.asyncSender
 	return topic, nil
 }
 
+// QPSMonitor returns the monitor of the topic, its RateLimiter reports the current rate
+// when the topic uses a rate limiter or an AdaptiveRateLimiter.
+func (p *MNSTopic) QPSMonitor() *QPSMonitor {
+	return p.qpsMonitor
+}
+
 func (p *MNSTopic) Name() string {
 	return p.name
 }
@@ -63,6 +78,13 @@ func (p *MNSTopic) GenerateMailEndpoint(mailAddress string) string {
 }
 
 func (p *MNSTopic) PublishMessage(message MessagePublishRequest) (resp MessageSendResponse, err error) {
+	if err = checkMessageProperties(message.UserProperties); err != nil {
+		return
+	}
+	if message.MessageBody, err = encodeMessageBody(p.codecs, message.MessageBody); err != nil {
+		return
+	}
+
 	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, POST, nil, message, fmt.Sprintf("topics/%s/%s", p.name, "messages"), &resp)
 	return
@@ -110,6 +132,7 @@ func (p *MNSTopic) GetSubscriptionAttributes(subscriptionName string) (attr Subs
 		return
 	}
 
+	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, GET, nil, nil, fmt.Sprintf("topics/%s/subscriptions/%s", p.name, subscriptionName), &attr)
 
 	return
@@ -122,6 +145,7 @@ func (p *MNSTopic) Unsubscribe(subscriptionName string) (err error) {
 		return
 	}
 
+	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, DELETE, nil, nil, fmt.Sprintf("topics/%s/subscriptions/%s", p.name, subscriptionName), nil)
 
 	return
@@ -151,6 +175,7 @@ func (p *MNSTopic) ListSubscriptionByTopic(nextMarker string, retNumber int32, p
 		header["x-mns-prefix"] = prefix
 	}
 
+	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, GET, header, nil, fmt.Sprintf("topics/%s/subscriptions", p.name), &subscriptions)
 
 	return
@@ -182,6 +207,7 @@ func (p *MNSTopic) ListSubscriptionDetailByTopic(nextMarker string, retNumber in
 
 	header["x-mns-with-meta"] = "true"
 
+	p.qpsMonitor.checkQPS()
 	_, err = send(p.client, p.decoder, GET, header, nil, fmt.Sprintf("topics/%s/subscriptions", p.name), &subscriptionDetails)
 
 	return
@@ -0,0 +1,183 @@
+package ali_mns
+
+import (
+	"errors"
+	"reflect"
+)
+
+const (
+	DefaultMessagePriority int64 = 8
+)
+
+// TypedMessage is a received message together with its decoded value. When the body can
+// not be decoded Err is set and Value is the zero value; Raw is always set so that poison
+// messages can still be inspected and deleted.
+type TypedMessage[T any] struct {
+	Value T
+	Raw   MessageReceiveResponse
+	Err   error
+}
+
+// TypedQueue sends and receives values of type T through an AliMNSQueue, converting them
+// with a Codec.
+type TypedQueue[T any] struct {
+	queue AliMNSQueue
+	codec Codec
+}
+
+func NewTypedQueue[T any](queue AliMNSQueue, codec Codec) *TypedQueue[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &TypedQueue[T]{queue: queue, codec: codec}
+}
+
+func (p *TypedQueue[T]) Queue() AliMNSQueue {
+	return p.queue
+}
+
+func (p *TypedQueue[T]) SendMessage(value T) (resp MessageSendResponse, err error) {
+	return p.SendMessageWithRequest(value, MessageSendRequest{Priority: DefaultMessagePriority})
+}
+
+// SendMessageWithRequest sends value using the delay and priority of request, the body of
+// request is replaced by the encoded value.
+func (p *TypedQueue[T]) SendMessageWithRequest(value T, request MessageSendRequest) (resp MessageSendResponse, err error) {
+	if request.MessageBody, err = p.codec.Marshal(value); err != nil {
+		return
+	}
+	return p.queue.SendMessage(request)
+}
+
+func (p *TypedQueue[T]) BatchSendMessage(values ...T) (resp BatchMessageSendResponse, err error) {
+	messages := make([]MessageSendRequest, 0, len(values))
+	for _, value := range values {
+		message := MessageSendRequest{Priority: DefaultMessagePriority}
+		if message.MessageBody, err = p.codec.Marshal(value); err != nil {
+			return
+		}
+		messages = append(messages, message)
+	}
+	return p.queue.BatchSendMessage(messages...)
+}
+
+// ReceiveMessage receives one message. A body which can not be decoded is reported both
+// through the returned error and the Err field of the message.
+func (p *TypedQueue[T]) ReceiveMessage(waitseconds ...int64) (msg TypedMessage[T], err error) {
+	resp, err := receiveMessage(p.queue, waitseconds...)
+	return p.decodeOne(resp, err)
+}
+
+func (p *TypedQueue[T]) PeekMessage() (msg TypedMessage[T], err error) {
+	resp, err := peekMessage(p.queue)
+	return p.decodeOne(resp, err)
+}
+
+// BatchReceiveMessage receives up to numOfMessages messages. Decode failures are reported
+// per message through the Err field, err is only set when the receive call itself failed.
+func (p *TypedQueue[T]) BatchReceiveMessage(numOfMessages int32, waitseconds ...int64) (msgs []TypedMessage[T], err error) {
+	resp, err := batchReceiveMessage(p.queue, numOfMessages, waitseconds...)
+	return p.decodeBatch(resp, err)
+}
+
+func (p *TypedQueue[T]) BatchPeekMessage(numOfMessages int32) (msgs []TypedMessage[T], err error) {
+	resp, err := batchPeekMessage(p.queue, numOfMessages)
+	return p.decodeBatch(resp, err)
+}
+
+func (p *TypedQueue[T]) DeleteMessage(receiptHandle string) (err error) {
+	return p.queue.DeleteMessage(receiptHandle)
+}
+
+func (p *TypedQueue[T]) decodeOne(resp MessageReceiveResponse, err error) (msg TypedMessage[T], _ error) {
+	if err == nil {
+		msg = p.decode(resp)
+		return msg, msg.Err
+	}
+
+	var decodeErr *MessageBodyDecodeError
+	if errors.As(err, &decodeErr) && len(decodeErr.Messages) == 1 {
+		msg.Raw = decodeErr.Messages[0]
+		msg.Err = err
+	}
+	return msg, err
+}
+
+func (p *TypedQueue[T]) decodeBatch(resp BatchMessageReceiveResponse, err error) (msgs []TypedMessage[T], _ error) {
+	var decodeErr *MessageBodyDecodeError
+	if errors.As(err, &decodeErr) {
+		for i, raw := range decodeErr.Messages {
+			if e, failed := decodeErr.Errors[i]; failed {
+				msgs = append(msgs, TypedMessage[T]{Raw: raw, Err: e})
+			} else {
+				msgs = append(msgs, p.decode(raw))
+			}
+		}
+		return msgs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, raw := range resp.Messages {
+		msgs = append(msgs, p.decode(raw))
+	}
+	return msgs, nil
+}
+
+func (p *TypedQueue[T]) decode(raw MessageReceiveResponse) TypedMessage[T] {
+	return DecodeTypedMessage[T](p.codec, raw)
+}
+
+// TypedTopic publishes values of type T through an AliMNSTopic, converting them with a Codec.
+type TypedTopic[T any] struct {
+	topic AliMNSTopic
+	codec Codec
+}
+
+func NewTypedTopic[T any](topic AliMNSTopic, codec Codec) *TypedTopic[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &TypedTopic[T]{topic: topic, codec: codec}
+}
+
+func (p *TypedTopic[T]) Topic() AliMNSTopic {
+	return p.topic
+}
+
+func (p *TypedTopic[T]) PublishMessage(value T) (resp MessageSendResponse, err error) {
+	return p.PublishMessageWithRequest(value, MessagePublishRequest{})
+}
+
+// PublishMessageWithRequest publishes value using the tag and attributes of request, the
+// body of request is replaced by the encoded value.
+func (p *TypedTopic[T]) PublishMessageWithRequest(value T, request MessagePublishRequest) (resp MessageSendResponse, err error) {
+	if request.MessageBody, err = p.codec.Marshal(value); err != nil {
+		return
+	}
+	return p.topic.PublishMessage(request)
+}
+
+// DecodeTypedMessage decodes a message received through any other API, e.g. from a
+// subscription queue of a TypedTopic.
+func DecodeTypedMessage[T any](codec Codec, raw MessageReceiveResponse) TypedMessage[T] {
+	msg := TypedMessage[T]{Raw: raw}
+	msg.Value, msg.Err = decodeTypedValue[T](codec, raw.MessageBody)
+	return msg
+}
+
+func decodeTypedValue[T any](codec Codec, body string) (value T, err error) {
+	var target interface{} = &value
+	// allocate the pointee when T is a pointer type so that unmarshalers see a usable value
+	if t := reflect.TypeOf(value); t != nil && t.Kind() == reflect.Ptr {
+		value = reflect.New(t.Elem()).Interface().(T)
+		target = value
+	}
+
+	if err = codec.Unmarshal(body, target); err != nil {
+		var zero T
+		return zero, err
+	}
+	return
+}
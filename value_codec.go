@@ -0,0 +1,94 @@
+package ali_mns
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec converts typed values to message bodies and back, it is used by TypedQueue
+// and TypedTopic.
+type Codec interface {
+	Marshal(v interface{}) (string, error)
+	Unmarshal(body string, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (JSONCodec) Unmarshal(body string, v interface{}) error {
+	return json.Unmarshal([]byte(body), v)
+}
+
+// BinaryCodec encodes values with their own binary format and wraps the result in
+// base64. Values must implement encoding.BinaryMarshaler or provide a protobuf style
+// Marshal() ([]byte, error) method, pointers to values the matching unmarshal method.
+type BinaryCodec struct{}
+
+type binaryMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type binaryUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+func (BinaryCodec) Marshal(v interface{}) (string, error) {
+	var b []byte
+	var err error
+	switch m := v.(type) {
+	case encoding.BinaryMarshaler:
+		b, err = m.MarshalBinary()
+	case binaryMarshaler:
+		b, err = m.Marshal()
+	default:
+		return "", fmt.Errorf("ali_mns: %T does not implement a binary marshaler", v)
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (BinaryCodec) Unmarshal(body string, v interface{}) error {
+	b, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return err
+	}
+	switch m := v.(type) {
+	case encoding.BinaryUnmarshaler:
+		return m.UnmarshalBinary(b)
+	case binaryUnmarshaler:
+		return m.Unmarshal(b)
+	}
+	return fmt.Errorf("ali_mns: %T does not implement a binary unmarshaler", v)
+}
+
+// MsgpackCodec encodes values with MessagePack and wraps the result in base64.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) (string, error) {
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (MsgpackCodec) Unmarshal(body string, v interface{}) error {
+	b, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return err
+	}
+	return msgpack.Unmarshal(b, v)
+}